@@ -1,6 +1,7 @@
 package plc
 
 import (
+	"sort"
 	"sync"
 )
 
@@ -19,45 +20,74 @@ type job struct {
 
 type async struct {
 	action
-	jobs    chan job
+	jobs    chan job // Jobs from Add, which are handed to workers by the dispatcher
+	work    chan job // Jobs from the dispatcher, which are taken by idle workers
 	cancel  chan struct{}
 	wg      sync.WaitGroup
 	errOnce sync.Once
 	err     error
 }
 
+// defaultMaxRoutines is the default limit on how many actions an async runs concurrently.
+const defaultMaxRoutines = 128
+
 func newAsync(act action) *async {
+	return newAsyncLimited(act, defaultMaxRoutines)
+}
+
+// newAsyncLimited returns an async which runs up to maxRoutines actions concurrently.
+func newAsyncLimited(act action, maxRoutines int) *async {
+	if maxRoutines < 1 {
+		maxRoutines = 1
+	}
 	as := &async{
 		action: act,
 		jobs:   make(chan job, 1),
+		work:   make(chan job),
 		cancel: make(chan struct{}),
 	}
 
 	as.wg.Add(1)
-	go func() {
-		// Listen for new jobs and launch them in new goroutines until the limit has been reached.
-		numRoutines := 0
-		var maxRoutines = 128
+	go as.dispatch(maxRoutines)
 
-		defer as.wg.Done()
-		for {
-			select {
-			case <-as.cancel:
+	return as
+}
+
+// dispatch hands each new job to an idle worker. If none is idle, a new worker is launched,
+// unless the limit has been reached, in which case it waits for a worker to become idle.
+func (as *async) dispatch(maxRoutines int) {
+	defer as.wg.Done()
+	defer close(as.work) // Tells the workers there are no more jobs
+
+	numRoutines := 0
+	for {
+		select {
+		case <-as.cancel:
+			return
+		case newJob, ok := <-as.jobs:
+			if !ok {
 				return
-			case newJob, ok := <-as.jobs:
-				if !ok {
-					return
-				}
+			}
+
+			select {
+			case as.work <- newJob:
+				continue // An idle worker took it
+			default:
+			}
+
+			if numRoutines < maxRoutines {
 				numRoutines++
 				as.launchRoutine(newJob)
-				if numRoutines >= maxRoutines {
-					return
-				}
+				continue
 			}
-		}
-	}()
 
-	return as
+			select {
+			case as.work <- newJob:
+			case <-as.cancel:
+				return
+			}
+		}
+	}
 }
 
 func (as *async) launchRoutine(newJob job) {
@@ -65,12 +95,10 @@ func (as *async) launchRoutine(newJob job) {
 	go func() {
 		defer as.wg.Done()
 
-		pendingJobs := true
-		for pendingJobs {
+		for pendingJobs := true; pendingJobs; newJob, pendingJobs = <-as.work {
 			if err := as.takeAction(newJob); err != nil {
 				return
 			}
-			newJob, pendingJobs = <-as.jobs
 		}
 	}()
 }
@@ -150,3 +178,79 @@ func (nas *notAsync) AddError(err error) {
 		nas.error = err
 	}
 }
+
+// collectingAsyncer wraps another asyncer so an error doesn't stop the other actions.
+// Instead, every error is collected, and Wait returns them all as a MultiError.
+type collectingAsyncer struct {
+	asyncer
+	mutex sync.Mutex
+	next  int // The order of the next action or error, so errors can be sorted
+	errs  []orderedError
+}
+
+type orderedError struct {
+	order int
+	err   error
+}
+
+// orderedValue is passed to the wrapped asyncer in place of a value, so each action's error
+// is sorted by when it was added, even if the same name is added more than once.
+type orderedValue struct {
+	order int
+	value interface{}
+}
+
+func newCollectingAsyncer(newAsyncer func(action) asyncer, act action) *collectingAsyncer {
+	ca := &collectingAsyncer{}
+	ca.asyncer = newAsyncer(func(name string, value interface{}) error {
+		ov := value.(orderedValue)
+		ca.addError(ov.order, act(name, ov.value))
+		return nil // the error has been collected, so don't cancel the others
+	})
+	return ca
+}
+
+func (ca *collectingAsyncer) Add(name string, value interface{}) {
+	ca.asyncer.Add(name, orderedValue{ca.nextOrder(), value})
+}
+
+func (ca *collectingAsyncer) AddError(err error) {
+	if err != nil {
+		ca.addError(ca.nextOrder(), err) // An error which wasn't from an action goes in the order it occurred
+	}
+}
+
+func (ca *collectingAsyncer) nextOrder() int {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+	order := ca.next
+	ca.next++
+	return order
+}
+
+func (ca *collectingAsyncer) addError(order int, err error) {
+	if err == nil {
+		return
+	}
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+	ca.errs = append(ca.errs, orderedError{order, err})
+}
+
+func (ca *collectingAsyncer) Wait() error {
+	if err := ca.asyncer.Wait(); err != nil {
+		ca.AddError(err)
+	}
+
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+	if len(ca.errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(ca.errs, func(i, j int) bool { return ca.errs[i].order < ca.errs[j].order })
+	errs := make(MultiError, len(ca.errs))
+	for i, oe := range ca.errs {
+		errs[i] = oe.err
+	}
+	return errs
+}
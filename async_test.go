@@ -3,6 +3,7 @@ package plc
 import (
 	"errors"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -115,3 +116,57 @@ outerLoop:
 	assert.NoError(t, err)
 	assert.Equal(t, sendVals, receiveVals)
 }
+
+func TestAsyncLimited(t *testing.T) {
+	const limit = 3
+	const numToTest = 20
+
+	var mutex sync.Mutex
+	running, maxRunning, completed := 0, 0, 0
+
+	as := newAsyncLimited(func(nm string, val interface{}) error {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mutex.Lock()
+		running--
+		completed++
+		mutex.Unlock()
+		return nil
+	}, limit)
+
+	for i := 0; i < numToTest; i++ {
+		as.Add(strconv.Itoa(i), i)
+	}
+
+	assert.NoError(t, as.Wait())
+	assert.Equal(t, numToTest, completed, "Every job should run, even beyond the limit")
+	assert.Equal(t, limit, maxRunning, "The limit should be reached but never exceeded")
+}
+
+func TestCollectingAsyncerDuplicateNames(t *testing.T) {
+	release := make(chan struct{})
+	ca := newCollectingAsyncer(func(act action) asyncer { return newAsyncLimited(act, 3) }, func(nm string, val interface{}) error {
+		<-release // Every action is added before any fails
+		return errors.New(nm + strconv.Itoa(val.(int)))
+	})
+	ca.Add("A", 1)
+	ca.Add("B", 2)
+	ca.Add("A", 3)
+	close(release)
+
+	err := ca.Wait()
+	multi, ok := err.(MultiError)
+	assert.True(t, ok, "Error should be a MultiError, but got %T", err)
+	var msgs []string
+	for _, e := range multi {
+		msgs = append(msgs, e.Error())
+	}
+	assert.Equal(t, []string{"A1", "B2", "A3"}, msgs, "Errors should be in the order they were added")
+}
@@ -0,0 +1,95 @@
+package plc
+
+import (
+	"fmt"
+)
+
+// TagValue pairs a tag name with the value to read into or write from.
+// After a batch operation, Err holds the result of the operation on this tag.
+type TagValue struct {
+	Name  string
+	Value interface{}
+	Err   error
+}
+
+// BatchReader is the interface that wraps the ReadTags method.
+type BatchReader interface {
+	// ReadTags reads each of the requested tags into its value.
+	// The result for each tag is stored in its Err field. The returned error is
+	// nil if all tags were read successfully; otherwise it is a BatchError.
+	ReadTags(tags []TagValue) error
+}
+
+// BatchWriter is the interface that wraps the WriteTags method.
+type BatchWriter interface {
+	// WriteTags writes each of the provided tags and values.
+	// The result for each tag is stored in its Err field. The returned error is
+	// nil if all tags were written successfully; otherwise it is a BatchError.
+	WriteTags(tags []TagValue) error
+}
+
+// ReadTags reads all of the tags from the Reader.
+// If the Reader is a BatchReader, its ReadTags is used. Otherwise the tags are read in parallel.
+func ReadTags(rd Reader, tags []TagValue) error {
+	if brd, ok := rd.(BatchReader); ok {
+		return brd.ReadTags(tags)
+	}
+	return batchAsync(rd.ReadTag, tags)
+}
+
+// WriteTags writes all of the tags to the Writer.
+// If the Writer is a BatchWriter, its WriteTags is used. Otherwise the tags are written in parallel.
+// Since the writes are parallel, the order in which they're applied is not defined.
+func WriteTags(wr Writer, tags []TagValue) error {
+	if bwr, ok := wr.(BatchWriter); ok {
+		return bwr.WriteTags(tags)
+	}
+	return batchAsync(wr.WriteTag, tags)
+}
+
+// batchAsync runs the action on every tag in parallel. Unlike other uses of async,
+// a failure doesn't cancel the other actions, since each tag is independent.
+func batchAsync(act action, tags []TagValue) error {
+	as := newAsync(func(_ string, value interface{}) error {
+		tag := value.(*TagValue)
+		tag.Err = act(tag.Name, tag.Value)
+		return nil // the error has been recorded in the tag, so don't cancel the others
+	})
+	for i := range tags {
+		as.Add(tags[i].Name, &tags[i])
+	}
+	as.Wait()
+	return BatchResult(tags)
+}
+
+// BatchResult returns nil if every tag succeeded, or a BatchError if any failed.
+// It is useful for implementing BatchReader and BatchWriter.
+func BatchResult(tags []TagValue) error {
+	err := BatchError{Total: len(tags)}
+	for _, tag := range tags {
+		if tag.Err == nil {
+			continue
+		}
+		if err.Failed == 0 {
+			err.First = tag.Err
+		}
+		err.Failed++
+	}
+	if err.Failed == 0 {
+		return nil
+	}
+	return err
+}
+
+// BatchError is returned by a batch operation if one or more tags failed.
+// The error for each tag is stored in its TagValue.
+type BatchError struct {
+	Failed, Total int
+	First         error // The error from the first failed tag
+}
+
+func (err BatchError) Error() string {
+	return fmt.Sprintf("%d of %d tags failed; first error: %v", err.Failed, err.Total, err.First)
+}
+
+func (err BatchError) Unwrap() error { return err.First }
@@ -0,0 +1,79 @@
+package plc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTags(t *testing.T) {
+	fakeRW := FakeReadWriter{"A": 1, "B": 2, "C": 3}
+
+	var a, b, c int
+	tags := []TagValue{{Name: "A", Value: &a}, {Name: "B", Value: &b}, {Name: "C", Value: &c}}
+	err := ReadTags(fakeRW, tags)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, []int{a, b, c})
+	for _, tag := range tags {
+		assert.NoError(t, tag.Err)
+	}
+}
+
+func TestReadTagsPartialFailure(t *testing.T) {
+	fakeRW := FakeReadWriter{"A": 1, "C": 3}
+
+	var a, b, c int
+	tags := []TagValue{{Name: "A", Value: &a}, {Name: "B", Value: &b}, {Name: "C", Value: &c}}
+	err := ReadTags(fakeRW, tags)
+	require.Error(t, err)
+
+	var batchErr BatchError
+	require.True(t, errors.As(err, &batchErr), "Error should be a BatchError")
+	assert.Equal(t, BatchError{Failed: 1, Total: 3, First: tags[1].Err}, batchErr)
+
+	assert.NoError(t, tags[0].Err)
+	assert.Error(t, tags[1].Err, "Only the missing tag should fail")
+	assert.NoError(t, tags[2].Err)
+	assert.Equal(t, []int{1, 0, 3}, []int{a, b, c}, "Other tags should still be read")
+}
+
+func TestWriteTags(t *testing.T) {
+	fakeRW := FakeReadWriter{}
+	wr := newSerializedFake(fakeRW) // FakeReadWriter isn't safe for parallel writes
+
+	tags := []TagValue{{Name: "A", Value: 1}, {Name: "B", Value: 2}}
+	err := WriteTags(wr, tags)
+	require.NoError(t, err)
+	assert.Equal(t, FakeReadWriter{"A": 1, "B": 2}, fakeRW)
+}
+
+func TestPooledReadTags(t *testing.T) {
+	fakeRW := FakeReadWriter{"A": 1, "C": 3}
+	p := NewPooled(fakeRW, 2)
+
+	var a, b, c int
+	tags := []TagValue{{Name: "A", Value: &a}, {Name: "B", Value: &b}, {Name: "C", Value: &c}}
+	err := p.ReadTags(tags)
+	require.Error(t, err)
+	assert.NoError(t, tags[0].Err)
+	assert.Error(t, tags[1].Err)
+	assert.NoError(t, tags[2].Err)
+	assert.Equal(t, []int{1, 0, 3}, []int{a, b, c})
+}
+
+func TestPooledWriteTags(t *testing.T) {
+	fakeRW := FakeReadWriter{}
+	p := NewPooled(newSerializedFake(fakeRW), 2)
+
+	tags := []TagValue{{Name: "A", Value: 1}, {Name: "B", Value: 2}}
+	err := WriteTags(p, tags)
+	require.NoError(t, err)
+	assert.Equal(t, FakeReadWriter{"A": 1, "B": 2}, fakeRW)
+}
+
+// newSerializedFake serializes access to a FakeReadWriter.
+func newSerializedFake(fakeRW FakeReadWriter) ReadWriter {
+	return NewPooled(fakeRW, 1)
+}
@@ -1,19 +1,36 @@
 package plc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 )
 
 type Cache struct {
 	reader Reader
-	cache  map[string]interface{}
+	cache  map[string]cacheEntry
 	mutex  sync.RWMutex
+	now    func() time.Time
+
+	// MaxAge is how long a cached value remains fresh. Older values have QualityStale,
+	// and CacheReader returns ErrStaleTag instead of reading them.
+	// If MaxAge is 0, values never become stale.
+	MaxAge time.Duration
+}
+
+// cacheEntry is the state of a single tag in the cache.
+type cacheEntry struct {
+	value    interface{}
+	readTime time.Time // When value was read; zero if it never has been
+	err      error     // The error from the most recent read, if it failed
 }
 
-var _ = Reader(&Cache{}) // Compiler makes sure this type is a Reader
+var _ = Reader(&Cache{})             // Compiler makes sure this type is a Reader
+var _ = ContextReader(&Cache{})      // Compiler makes sure this type is a ContextReader
+var _ = ContextRangeReader(&Cache{}) // Compiler makes sure this type is a ContextRangeReader
 
 // NewCache returns a Cache which caches the most recent value passed through it.
 // Values are cached by reading them through NewCache as a Reader.
@@ -21,30 +38,87 @@ var _ = Reader(&Cache{}) // Compiler makes sure this type is a Reader
 func NewCache(reader Reader) *Cache {
 	return &Cache{
 		reader: reader,
-		cache:  map[string]interface{}{},
+		cache:  map[string]cacheEntry{},
+		now:    time.Now,
 	}
 }
 
 func (r *Cache) ReadTag(name string, value interface{}) error {
-	err := r.reader.ReadTag(name, value)
+	return r.ReadTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext reads through to the underlying Reader and caches the result.
+// If the read fails, the previously cached value is kept, but the error is recorded
+// so the cached value's Quality becomes QualityBad. Errors caused by ctx aren't recorded.
+func (r *Cache) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	err := NewContextReader(r.reader).ReadTagContext(ctx, name, value)
+
+	r.mutex.Lock()
 	if err != nil {
-		return fmt.Errorf("Cache: %w", err)
+		r.recordError(name, err)
+	} else {
+		r.store(name, reflect.Indirect(reflect.ValueOf(value)).Interface())
 	}
+	r.mutex.Unlock()
+
+	return WrapOpError(err, "Cache", "ReadTag", name)
+}
+
+func (r *Cache) ReadRange(name string, start int, value interface{}) error {
+	return r.ReadRangeContext(context.Background(), name, start, value)
+}
+
+// ReadRangeContext reads a range of the named array through to the underlying Reader, with one
+// call if it's a RangeReader, and caches each element by its own name (e.g. "TAG[100]").
+// Errors are recorded the same way as ReadTagContext.
+func (r *Cache) ReadRangeContext(ctx context.Context, name string, start int, value interface{}) error {
+	arr, err := RangeElements(name, value)
+	if err != nil {
+		return WrapOpError(err, "Cache", "ReadRange", name)
+	}
+	err = ReadRangeContext(ctx, r.reader, name, start, value)
 
 	r.mutex.Lock()
-	r.cache[name] = reflect.Indirect(reflect.ValueOf(value)).Interface()
+	for i := 0; i < arr.Len(); i++ {
+		if err != nil {
+			r.recordError(TagWithIndex(name, start+i), err)
+		} else {
+			r.store(TagWithIndex(name, start+i), arr.Index(i).Interface())
+		}
+	}
 	r.mutex.Unlock()
 
-	return nil
+	return WrapOpError(err, "Cache", "ReadRange", name)
+}
+
+// store caches a successfully read value. The caller must hold the write lock.
+func (r *Cache) store(name string, value interface{}) {
+	r.cache[name] = cacheEntry{
+		value:    value,
+		readTime: r.now(),
+	}
+}
+
+// recordError records a failed read, keeping the previously cached value.
+// An error caused by the context being cancelled or timing out says nothing about the tag,
+// so it isn't recorded. The caller must hold the write lock.
+func (r *Cache) recordError(name string, err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	entry := r.cache[name]
+	entry.err = err
+	r.cache[name] = entry
 }
 
 // ReadCachedTag acts the same as ReadTag, but returns the cached value.
 // A read of a value not in the cache will return ErrTagNotFound.
+// The most recent successfully read value is returned, even if it is stale or a later read failed.
 func (r *Cache) ReadCachedTag(name string, value interface{}) error {
 	r.mutex.RLock()
-	cVal, ok := r.cache[name]
+	entry, ok := r.cache[name]
 	r.mutex.RUnlock()
-	if !ok {
+	if !ok || entry.readTime.IsZero() {
 		return ErrTagNotFound{name}
 	}
 
@@ -57,17 +131,79 @@ func (r *Cache) ReadCachedTag(name string, value interface{}) error {
 		return errors.New("Provided value for tag '" + name + "' cannot be set")
 	}
 
-	vToSet.Set(reflect.ValueOf(cVal))
+	vToSet.Set(reflect.ValueOf(entry.value))
 	return nil
 }
 
+// Quality indicates whether a cached value can be trusted, similar to OPC quality codes.
+type Quality int
+
+const (
+	// QualityGood means the value was read successfully, and recently.
+	QualityGood = Quality(iota)
+	// QualityStale means the value was read successfully, but it is older than the Cache's MaxAge.
+	QualityStale
+	// QualityBad means the most recent read failed, so the value might be out of date.
+	QualityBad
+)
+
+func (q Quality) String() string {
+	switch q {
+	case QualityGood:
+		return "Good"
+	case QualityStale:
+		return "Stale"
+	case QualityBad:
+		return "Bad"
+	default:
+		return fmt.Sprintf("Quality(%d)", int(q))
+	}
+}
+
+// Sample is a cached value along with information about when and how well it was read.
+type Sample struct {
+	Value   interface{} // The most recent successfully read value, or nil if there has never been one
+	Time    time.Time   // When Value was read
+	Err     error       // The error from the most recent read, or nil if it succeeded
+	Quality Quality
+}
+
+// ReadCachedSample returns the cached sample for the tag.
+// If the tag has never been read, it returns ErrTagNotFound. If it has been read but every read
+// failed, the sample has no Value and QualityBad.
+func (r *Cache) ReadCachedSample(name string) (Sample, error) {
+	r.mutex.RLock()
+	entry, ok := r.cache[name]
+	r.mutex.RUnlock()
+	if !ok {
+		return Sample{}, ErrTagNotFound{name}
+	}
+
+	sample := Sample{
+		Value: entry.value,
+		Time:  entry.readTime,
+		Err:   entry.err,
+	}
+	switch {
+	case entry.err != nil:
+		sample.Quality = QualityBad
+	case r.MaxAge > 0 && r.now().Sub(entry.readTime) > r.MaxAge:
+		sample.Quality = QualityStale
+	default:
+		sample.Quality = QualityGood
+	}
+	return sample, nil
+}
+
 func (r *Cache) Keys() []string {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
 	keys := make([]string, 0, len(r.cache))
-	for key := range r.cache {
-		keys = append(keys, key)
+	for key, entry := range r.cache {
+		if !entry.readTime.IsZero() {
+			keys = append(keys, key)
+		}
 	}
 	return keys
 }
@@ -77,16 +213,23 @@ type CacheReader struct {
 }
 
 // CacheReader returns a Reader which calls ReadCachedTag.
+// If the Cache has a MaxAge, values older than MaxAge are not read, and ErrStaleTag is returned instead.
 func (r *Cache) CacheReader() CacheReader {
 	return CacheReader{cache: r}
 }
 
 func (r CacheReader) ReadTag(name string, value interface{}) error {
-	err := r.cache.ReadCachedTag(name, value)
-	if err != nil {
-		return fmt.Errorf("CacheReader: %w", err)
+	if r.cache.MaxAge > 0 {
+		r.cache.mutex.RLock()
+		entry, ok := r.cache.cache[name]
+		r.cache.mutex.RUnlock()
+		if age := r.cache.now().Sub(entry.readTime); ok && !entry.readTime.IsZero() && age > r.cache.MaxAge {
+			return WrapOpError(ErrStaleTag{Name: name, Age: age, Err: entry.err}, "CacheReader", "ReadTag", name)
+		}
 	}
-	return nil
+
+	err := r.cache.ReadCachedTag(name, value)
+	return WrapOpError(err, "CacheReader", "ReadTag", name)
 }
 
 type ErrTagNotFound struct {
@@ -98,3 +241,106 @@ func (err ErrTagNotFound) Error() string {
 }
 
 func (err ErrTagNotFound) Unwrap() error { return ErrBadRequest }
+
+// ErrStaleTag is returned by CacheReader if the cached value is older than the Cache's MaxAge.
+// It matches ErrStale, so staleness can be checked with errors.Is.
+type ErrStaleTag struct {
+	Name string
+	Age  time.Duration
+	Err  error // The error from the most recent read, if it failed
+}
+
+func (err ErrStaleTag) Error() string {
+	msg := fmt.Sprintf("Cache tag '%s' is stale (read %v ago)", err.Name, err.Age)
+	if err.Err != nil {
+		msg += fmt.Sprintf(" because the last read failed: %v", err.Err)
+	}
+	return msg
+}
+
+// Is reports whether target is ErrStale.
+func (err ErrStaleTag) Is(target error) bool { return target == ErrStale }
+
+// Unwrap returns the error from the most recent read, which is probably why the value is stale.
+// It returns nil if the most recent read succeeded.
+func (err ErrStaleTag) Unwrap() error { return err.Err }
+
+// WriteThroughCache is a Cache which also passes writes through to the underlying ReadWriter.
+// After a successful write, the cached value of the written tag is updated, so ReadCachedTag
+// returns what was written. Cached values of related tags (i.e. fields or elements of the
+// written tag, and structs or arrays which contain it) are invalidated, since they are now
+// out of date. If a write fails, the written tag is invalidated too.
+type WriteThroughCache struct {
+	*Cache
+	writer Writer
+}
+
+var _ = ReadWriter(&WriteThroughCache{})        // Compiler makes sure this type is a ReadWriter
+var _ = ContextReadWriter(&WriteThroughCache{}) // Compiler makes sure this type is a ContextReadWriter
+
+// NewWriteThroughCache returns a WriteThroughCache which reads and writes through rw.
+func NewWriteThroughCache(rw ReadWriter) *WriteThroughCache {
+	return &WriteThroughCache{
+		Cache:  NewCache(rw),
+		writer: rw,
+	}
+}
+
+func (r *WriteThroughCache) WriteTag(name string, value interface{}) error {
+	return r.WriteTagContext(context.Background(), name, value)
+}
+
+// WriteTagContext writes through to the underlying Writer and updates the cache.
+func (r *WriteThroughCache) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	err := NewContextWriter(r.writer).WriteTagContext(ctx, name, value)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.invalidateRelated(name)
+
+	if err != nil {
+		delete(r.cache, name) // The write may or may not have happened
+		return WrapOpError(err, "WriteThroughCache", "WriteTag", name)
+	}
+
+	newVal := reflect.Indirect(reflect.ValueOf(value))
+	entry, ok := r.cache[name]
+	if !ok || !newVal.IsValid() || entry.readTime.IsZero() || reflect.TypeOf(entry.value) != newVal.Type() {
+		// Readers might use a different type than was written (e.g. a struct was read, but
+		// only a pointer to it was written), so only update values of the same type.
+		// A nil value (or nil pointer) has no value to cache.
+		delete(r.cache, name)
+		return nil
+	}
+
+	r.cache[name] = cacheEntry{
+		value:    newVal.Interface(),
+		readTime: r.now(),
+	}
+	return nil
+}
+
+// invalidateRelated removes all cached tags which contain the named tag or are contained by it.
+// The named tag itself is not removed. The caller must hold the write lock.
+func (r *WriteThroughCache) invalidateRelated(name string) {
+	path, err := ParseTagPath(name)
+	if err != nil {
+		return // If the name can't be parsed, nothing could be related to it
+	}
+	path = path.Word() // Writing a bit changes the integer, and so all of its other bits
+
+	for key := range r.cache {
+		if key == name {
+			continue
+		}
+		keyPath, err := ParseTagPath(key)
+		if err != nil {
+			continue
+		}
+		keyPath = keyPath.Word()
+		if keyPath.HasPrefix(path) || path.HasPrefix(keyPath) {
+			delete(r.cache, key)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package plc
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -124,3 +127,269 @@ func TestCacheReader(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 7, actual)
 }
+
+// fakeClock is used to control the Cache's idea of the current time.
+type fakeClock struct {
+	time.Time
+}
+
+func (fc *fakeClock) now() time.Time {
+	return fc.Time
+}
+
+func newCacheWithClockForTesting() (*Cache, FakeReadWriter, *fakeClock) {
+	cache, fakeRW := newCacheForTesting()
+	clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cache.now = clock.now
+	return cache, fakeRW, clock
+}
+
+func TestCacheSampleGood(t *testing.T) {
+	cache, fakeRW, clock := newCacheWithClockForTesting()
+	fakeRW[testTagName] = 7
+
+	var unused int
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+
+	sample, err := cache.ReadCachedSample(testTagName)
+	require.NoError(t, err)
+	assert.Equal(t, Sample{Value: 7, Time: clock.Time, Quality: QualityGood}, sample)
+}
+
+func TestCacheSampleNotFound(t *testing.T) {
+	cache, _ := newCacheForTesting()
+	_, err := cache.ReadCachedSample(testTagName)
+	assert.Equal(t, ErrTagNotFound{testTagName}, err)
+}
+
+func TestCacheSampleBadAfterFailedRead(t *testing.T) {
+	cache, fakeRW, clock := newCacheWithClockForTesting()
+	fakeRW[testTagName] = 7
+
+	var unused int
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+	readTime := clock.Time
+
+	clock.Time = clock.Add(time.Minute)
+	delete(fakeRW, testTagName)
+	require.Error(t, cache.ReadTag(testTagName, &unused))
+
+	sample, err := cache.ReadCachedSample(testTagName)
+	require.NoError(t, err)
+	assert.Equal(t, 7, sample.Value, "Last good value should be kept")
+	assert.Equal(t, readTime, sample.Time, "Time should be of the last good value")
+	assert.Error(t, sample.Err)
+	assert.Equal(t, QualityBad, sample.Quality)
+
+	// ReadCachedTag still provides the old value
+	var actual int
+	require.NoError(t, cache.ReadCachedTag(testTagName, &actual))
+	assert.Equal(t, 7, actual)
+
+	// A successful read restores the quality
+	fakeRW[testTagName] = 8
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+	sample, err = cache.ReadCachedSample(testTagName)
+	require.NoError(t, err)
+	assert.Equal(t, Sample{Value: 8, Time: clock.Time, Quality: QualityGood}, sample)
+}
+
+func TestCacheSampleGoodAfterCancelledRead(t *testing.T) {
+	cache, fakeRW, clock := newCacheWithClockForTesting()
+	fakeRW[testTagName] = 7
+
+	var unused int
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := cache.ReadTagContext(ctx, testTagName, &unused)
+	require.True(t, errors.Is(err, context.Canceled), "Read should fail with the context's error: %v", err)
+
+	sample, err := cache.ReadCachedSample(testTagName)
+	require.NoError(t, err)
+	assert.Equal(t, Sample{Value: 7, Time: clock.Time, Quality: QualityGood}, sample, "The PLC read never failed")
+
+	// A cancelled read of a new tag doesn't add it to the cache
+	require.Error(t, cache.ReadTagContext(ctx, secondTestTagName, &unused))
+	_, err = cache.ReadCachedSample(secondTestTagName)
+	assert.Equal(t, ErrTagNotFound{secondTestTagName}, err)
+}
+
+func TestCacheSampleNeverRead(t *testing.T) {
+	cache, _ := newCacheForTesting()
+
+	var unused int
+	require.Error(t, cache.ReadTag(testTagName, &unused))
+
+	sample, err := cache.ReadCachedSample(testTagName)
+	require.NoError(t, err)
+	assert.Nil(t, sample.Value)
+	assert.Equal(t, QualityBad, sample.Quality)
+
+	err = cache.ReadCachedTag(testTagName, &unused)
+	assert.Equal(t, ErrTagNotFound{testTagName}, err, "There is no value to read")
+	assert.Empty(t, cache.Keys())
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	cache, fakeRW, clock := newCacheWithClockForTesting()
+	cache.MaxAge = time.Second
+	fakeRW[testTagName] = 7
+
+	var unused int
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+
+	clock.Time = clock.Add(time.Second)
+	var actual int
+	require.NoError(t, cache.CacheReader().ReadTag(testTagName, &actual), "Value is exactly MaxAge, so it's still fresh")
+	assert.Equal(t, 7, actual)
+
+	clock.Time = clock.Add(time.Millisecond)
+	sample, err := cache.ReadCachedSample(testTagName)
+	require.NoError(t, err)
+	assert.Equal(t, QualityStale, sample.Quality)
+
+	err = cache.CacheReader().ReadTag(testTagName, &actual)
+	var staleErr ErrStaleTag
+	require.True(t, errors.As(err, &staleErr), "Error should be ErrStaleTag")
+	assert.Equal(t, ErrStaleTag{Name: testTagName, Age: time.Second + time.Millisecond}, staleErr)
+	assert.True(t, errors.Is(err, ErrStale), "Error should be ErrStale")
+}
+
+func TestCacheMaxAgeAfterFailure(t *testing.T) {
+	cache, fakeRW, clock := newCacheWithClockForTesting()
+	cache.MaxAge = time.Second
+	fakeRW[testTagName] = 7
+
+	var unused int
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+
+	clock.Time = clock.Add(time.Minute)
+	fakeRW[testTagName] = "wrong type"
+	require.Error(t, cache.ReadTag(testTagName, &unused))
+
+	err := cache.CacheReader().ReadTag(testTagName, &unused)
+	var staleErr ErrStaleTag
+	require.True(t, errors.As(err, &staleErr), "Error should be ErrStaleTag")
+	assert.Error(t, staleErr.Err, "The stale error should include the failed read")
+	assert.True(t, errors.Is(err, ErrStale), "Error should be ErrStale")
+	assert.True(t, errors.Is(err, staleErr.Err), "Error should still wrap the failed read")
+}
+
+func newWriteThroughCacheForTesting() (*WriteThroughCache, FakeReadWriter) {
+	fakeRW := FakeReadWriter(map[string]interface{}{})
+	return NewWriteThroughCache(fakeRW), fakeRW
+}
+
+func TestWriteThroughCacheUpdatesValue(t *testing.T) {
+	cache, fakeRW := newWriteThroughCacheForTesting()
+	fakeRW[testTagName] = 7
+
+	var unused int
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+
+	require.NoError(t, cache.WriteTag(testTagName, 8))
+	assert.Equal(t, 8, fakeRW[testTagName], "Write should pass through")
+
+	var actual int
+	require.NoError(t, cache.ReadCachedTag(testTagName, &actual))
+	assert.Equal(t, 8, actual, "Cache should contain the written value")
+}
+
+func TestWriteThroughCacheWithPointer(t *testing.T) {
+	cache, fakeRW := newWriteThroughCacheForTesting()
+	fakeRW[testTagName] = 7
+
+	var unused int
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+
+	val := 8
+	require.NoError(t, cache.WriteTag(testTagName, &val))
+	val++ // Make sure the cache didn't keep the pointer
+
+	var actual int
+	require.NoError(t, cache.ReadCachedTag(testTagName, &actual))
+	assert.Equal(t, 8, actual)
+}
+
+func TestWriteThroughCacheInvalidatesDifferentType(t *testing.T) {
+	cache, fakeRW := newWriteThroughCacheForTesting()
+	fakeRW[testTagName] = 7
+
+	var unused int
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+	require.NoError(t, cache.WriteTag(testTagName, int32(8)))
+
+	err := cache.ReadCachedTag(testTagName, &unused)
+	assert.Equal(t, ErrTagNotFound{testTagName}, err)
+}
+
+func TestWriteThroughCacheNilPointer(t *testing.T) {
+	cache, fakeRW := newWriteThroughCacheForTesting()
+	fakeRW[testTagName] = 7
+
+	var unused int
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+	require.NoError(t, cache.WriteTag(testTagName, (*int)(nil)))
+
+	err := cache.ReadCachedTag(testTagName, &unused)
+	assert.Equal(t, ErrTagNotFound{testTagName}, err)
+
+	fakeRW[testTagName] = 7
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+	require.NoError(t, cache.WriteTag(testTagName, nil))
+	err = cache.ReadCachedTag(testTagName, &unused)
+	assert.Equal(t, ErrTagNotFound{testTagName}, err)
+}
+
+func TestWriteThroughCacheInvalidatesOnFailure(t *testing.T) {
+	cache, fakeRW := newWriteThroughCacheForTesting()
+	fakeRW[testTagName] = 7
+
+	var unused int
+	require.NoError(t, cache.ReadTag(testTagName, &unused))
+
+	cache.writer = writerFunc(func(string, interface{}) error { return ErrPlcConnection })
+	err := cache.WriteTag(testTagName, 8)
+	assert.True(t, errors.Is(err, ErrPlcConnection))
+
+	err = cache.ReadCachedTag(testTagName, &unused)
+	assert.Equal(t, ErrTagNotFound{testTagName}, err)
+}
+
+func TestWriteThroughCacheInvalidatesRelated(t *testing.T) {
+	tests := []struct {
+		written     string
+		invalidated []string
+		kept        []string
+	}{
+		{"Motor", []string{"Motor.Speed", "Motor.Arr[1]"}, []string{"Motors", "Other"}},
+		{"Motor.Speed", []string{"Motor"}, []string{"Motor.Arr[1]", "Motors", "Other"}},
+		{"Motor.Arr[1]", []string{"Motor"}, []string{"Motor.Speed", "Motor.Arr[10]", "Other"}},
+		{"Motor.Arr[10]", []string{"Motor"}, []string{"Motor.Speed", "Motor.Arr[1]", "Other"}},
+		{"Other", []string{}, []string{"Motor", "Motor.Speed", "Motor.Arr[1]"}},
+	}
+
+	allTags := []string{"Motor", "Motors", "Motor.Speed", "Motor.Arr[1]", "Motor.Arr[10]", "Other"}
+
+	for _, test := range tests {
+		t.Run(test.written, func(tt *testing.T) {
+			cache, fakeRW := newWriteThroughCacheForTesting()
+			var unused int
+			for _, tag := range allTags {
+				fakeRW[tag] = 7
+				require.NoError(tt, cache.ReadTag(tag, &unused))
+			}
+
+			require.NoError(tt, cache.WriteTag(test.written, 8))
+
+			for _, tag := range test.invalidated {
+				assert.Equal(tt, ErrTagNotFound{tag}, cache.ReadCachedTag(tag, &unused), "%s should be invalidated", tag)
+			}
+			for _, tag := range test.kept {
+				assert.NoError(tt, cache.ReadCachedTag(tag, &unused), "%s should be kept", tag)
+			}
+		})
+	}
+}
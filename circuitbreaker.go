@@ -0,0 +1,195 @@
+package plc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed passes all operations through. This is the normal state.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails all operations with ErrCircuitOpen without contacting the PLC.
+	CircuitOpen
+	// CircuitHalfOpen allows a single probe operation through to test whether the PLC is back.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "Closed"
+	case CircuitOpen:
+		return "Open"
+	case CircuitHalfOpen:
+		return "HalfOpen"
+	default:
+		return "Unknown"
+	}
+}
+
+// CircuitBreaker wraps another ReadWriter and fails fast when the PLC appears to be unreachable.
+// After a number of consecutive ErrPlcConnection errors, the circuit opens and all operations
+// fail with ErrCircuitOpen. After a cooldown, a single operation is let through as a probe.
+// If it doesn't fail with ErrPlcConnection, the circuit closes again. Otherwise it stays open
+// for another cooldown.
+type CircuitBreaker struct {
+	plc       ReadWriter
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mutex    sync.Mutex
+	state    CircuitState
+	failures int       // Consecutive connection failures while closed
+	openedAt time.Time // When the circuit last opened
+
+	// StateCallback, if not nil, is called after every state transition.
+	// It should be set before the CircuitBreaker is used.
+	StateCallback func(from, to CircuitState)
+}
+
+var _ = ReadWriter(&CircuitBreaker{})        // Compiler makes sure this type is a ReadWriter
+var _ = ContextReadWriter(&CircuitBreaker{}) // Compiler makes sure this type is a ContextReadWriter
+
+// CircuitBreakerOption configures a CircuitBreaker.
+type CircuitBreakerOption interface {
+	apply(*CircuitBreaker)
+}
+
+// circuitBreakerOptionFunc wraps a func so it satisfies the CircuitBreakerOption interface.
+type circuitBreakerOptionFunc func(*CircuitBreaker)
+
+func (f circuitBreakerOptionFunc) apply(cb *CircuitBreaker) { f(cb) }
+
+// CircuitThreshold sets how many consecutive connection errors open the circuit. The default is 5.
+func CircuitThreshold(failures int) CircuitBreakerOption {
+	return circuitBreakerOptionFunc(func(cb *CircuitBreaker) {
+		cb.threshold = failures
+	})
+}
+
+// CircuitCooldown sets how long the circuit stays open before a probe is allowed. The default is 10s.
+func CircuitCooldown(cooldown time.Duration) CircuitBreakerOption {
+	return circuitBreakerOptionFunc(func(cb *CircuitBreaker) {
+		cb.cooldown = cooldown
+	})
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker which passes operations through to plc.
+func NewCircuitBreaker(plc ReadWriter, opts ...CircuitBreakerOption) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		plc:       plc,
+		threshold: 5,
+		cooldown:  10 * time.Second,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt.apply(cb)
+	}
+	return cb
+}
+
+// State returns the current state of the circuit.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) ReadTag(name string, value interface{}) error {
+	return cb.ReadTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext reads the tag if the circuit allows it. Otherwise it returns ErrCircuitOpen.
+func (cb *CircuitBreaker) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	return cb.do(func() error {
+		return NewContextReader(cb.plc).ReadTagContext(ctx, name, value)
+	})
+}
+
+func (cb *CircuitBreaker) WriteTag(name string, value interface{}) error {
+	return cb.WriteTagContext(context.Background(), name, value)
+}
+
+// WriteTagContext writes the tag if the circuit allows it. Otherwise it returns ErrCircuitOpen.
+func (cb *CircuitBreaker) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	return cb.do(func() error {
+		return NewContextWriter(cb.plc).WriteTagContext(ctx, name, value)
+	})
+}
+
+func (cb *CircuitBreaker) do(op func() error) error {
+	probe, err := cb.before()
+	if err != nil {
+		return err
+	}
+	err = op()
+	cb.after(probe, err)
+	return err
+}
+
+// before returns ErrCircuitOpen if the operation is not allowed.
+// Otherwise it returns whether the operation is the half-open probe.
+func (cb *CircuitBreaker) before() (bool, error) {
+	cb.mutex.Lock()
+	switch {
+	case cb.state == CircuitClosed:
+		cb.mutex.Unlock()
+		return false, nil
+	case cb.state == CircuitOpen && cb.now().Sub(cb.openedAt) >= cb.cooldown:
+		cb.setState(CircuitHalfOpen) // unlocks
+		return true, nil
+	default:
+		cb.mutex.Unlock()
+		return false, ErrCircuitOpen // Either open, or half-open with the probe already running
+	}
+}
+
+// after updates the state based on the result of an operation.
+func (cb *CircuitBreaker) after(probe bool, err error) {
+	cb.mutex.Lock()
+	connectionFailed := errors.Is(err, ErrPlcConnection)
+
+	switch {
+	case probe && connectionFailed:
+		cb.openedAt = cb.now()
+		cb.setState(CircuitOpen)
+	case probe && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+		// The probe was abandoned without learning anything, so let the next operation probe.
+		cb.setState(CircuitOpen)
+	case probe:
+		cb.failures = 0
+		cb.setState(CircuitClosed)
+	case cb.state != CircuitClosed:
+		// Operations which started before the circuit opened don't change anything.
+		cb.mutex.Unlock()
+	case !connectionFailed:
+		cb.failures = 0
+		cb.mutex.Unlock()
+	default:
+		cb.failures++
+		if cb.failures < cb.threshold {
+			cb.mutex.Unlock()
+			return
+		}
+		cb.openedAt = cb.now()
+		cb.setState(CircuitOpen)
+	}
+}
+
+// setState must be called with the mutex held. It unlocks the mutex before calling StateCallback,
+// so the callback may use the CircuitBreaker.
+func (cb *CircuitBreaker) setState(to CircuitState) {
+	from := cb.state
+	cb.state = to
+	cb.mutex.Unlock()
+
+	if from != to && cb.StateCallback != nil {
+		cb.StateCallback(from, to)
+	}
+}
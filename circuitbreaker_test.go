@@ -0,0 +1,156 @@
+package plc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type circuitTransition struct {
+	from, to CircuitState
+}
+
+func newCircuitBreakerForTesting(failures int) (*CircuitBreaker, *failingReadWriter, *fakeClock, *[]circuitTransition) {
+	rw := &failingReadWriter{FakeReadWriter: FakeReadWriter{testTagName: 7}, failures: failures, err: errTestTransient}
+	cb := NewCircuitBreaker(rw, CircuitThreshold(3), CircuitCooldown(time.Second))
+	clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cb.now = clock.now
+
+	transitions := &[]circuitTransition{}
+	cb.StateCallback = func(from, to CircuitState) {
+		*transitions = append(*transitions, circuitTransition{from, to})
+	}
+	return cb, rw, clock, transitions
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	cb, rw, _, transitions := newCircuitBreakerForTesting(100)
+
+	var actual int
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, errTestTransient, cb.ReadTag(testTagName, &actual))
+	}
+	assert.Equal(t, CircuitOpen, cb.State())
+	assert.Equal(t, []circuitTransition{{CircuitClosed, CircuitOpen}}, *transitions)
+
+	assert.Equal(t, ErrCircuitOpen, cb.ReadTag(testTagName, &actual))
+	assert.Equal(t, ErrCircuitOpen, cb.WriteTag(testTagName, 8))
+	assert.Equal(t, 3, rw.calls, "Open circuit should not contact the PLC")
+}
+
+func TestCircuitBreakerSuccessResetsCount(t *testing.T) {
+	cb, rw, _, _ := newCircuitBreakerForTesting(2)
+
+	var actual int
+	assert.Error(t, cb.ReadTag(testTagName, &actual))
+	assert.Error(t, cb.ReadTag(testTagName, &actual))
+	assert.NoError(t, cb.ReadTag(testTagName, &actual))
+
+	rw.failures = rw.calls + 2
+	assert.Error(t, cb.ReadTag(testTagName, &actual))
+	assert.Error(t, cb.ReadTag(testTagName, &actual))
+	assert.Equal(t, CircuitClosed, cb.State(), "Failures weren't consecutive")
+}
+
+func TestCircuitBreakerIgnoresOtherErrors(t *testing.T) {
+	cb, rw, _, _ := newCircuitBreakerForTesting(100)
+	rw.err = ErrBadRequest
+
+	var actual int
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, ErrBadRequest, cb.ReadTag(testTagName, &actual))
+	}
+	assert.Equal(t, CircuitClosed, cb.State())
+}
+
+func TestCircuitBreakerProbeCloses(t *testing.T) {
+	cb, rw, clock, transitions := newCircuitBreakerForTesting(3)
+
+	var actual int
+	for i := 0; i < 3; i++ {
+		cb.ReadTag(testTagName, &actual)
+	}
+	require.Equal(t, CircuitOpen, cb.State())
+
+	clock.Time = clock.Add(time.Second)
+	assert.NoError(t, cb.ReadTag(testTagName, &actual))
+	assert.Equal(t, 7, actual)
+	assert.Equal(t, CircuitClosed, cb.State())
+	assert.Equal(t, 4, rw.calls)
+	assert.Equal(t, []circuitTransition{
+		{CircuitClosed, CircuitOpen},
+		{CircuitOpen, CircuitHalfOpen},
+		{CircuitHalfOpen, CircuitClosed},
+	}, *transitions)
+}
+
+func TestCircuitBreakerProbeFailureReopens(t *testing.T) {
+	cb, rw, clock, _ := newCircuitBreakerForTesting(100)
+
+	var actual int
+	for i := 0; i < 3; i++ {
+		cb.ReadTag(testTagName, &actual)
+	}
+
+	clock.Time = clock.Add(time.Second)
+	assert.Equal(t, errTestTransient, cb.ReadTag(testTagName, &actual))
+	assert.Equal(t, CircuitOpen, cb.State())
+
+	// The cooldown restarts from the failed probe
+	clock.Time = clock.Add(time.Second / 2)
+	assert.Equal(t, ErrCircuitOpen, cb.ReadTag(testTagName, &actual))
+	assert.Equal(t, 4, rw.calls)
+}
+
+// probeBlockingReadWriter blocks reads until release is closed.
+type probeBlockingReadWriter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (rw probeBlockingReadWriter) ReadTag(name string, value interface{}) error {
+	close(rw.started)
+	<-rw.release
+	return nil
+}
+
+func (rw probeBlockingReadWriter) WriteTag(name string, value interface{}) error {
+	return nil
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	rw := probeBlockingReadWriter{started: make(chan struct{}), release: make(chan struct{})}
+	cb := NewCircuitBreaker(rw, CircuitCooldown(0))
+	cb.state = CircuitOpen
+
+	result := goRead(cb, testTagName)
+	<-rw.started
+	assert.Equal(t, CircuitHalfOpen, cb.State())
+
+	var actual int
+	assert.Equal(t, ErrCircuitOpen, cb.ReadTag(testTagName, &actual), "Only one probe may run at a time")
+
+	close(rw.release)
+	assert.NoError(t, receiveError(t, result))
+	assert.Equal(t, CircuitClosed, cb.State())
+}
+
+func TestCircuitBreakerAbandonedProbe(t *testing.T) {
+	cb, rw, _, _ := newCircuitBreakerForTesting(0)
+	cb.state = CircuitOpen
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var actual int
+	assert.Equal(t, context.Canceled, cb.ReadTagContext(ctx, testTagName, &actual))
+	assert.Equal(t, CircuitOpen, cb.State())
+
+	// Since the abandoned probe didn't restart the cooldown, the next read probes again
+	assert.NoError(t, cb.ReadTag(testTagName, &actual))
+	assert.Equal(t, CircuitClosed, cb.State())
+	assert.Equal(t, 1, rw.calls)
+}
@@ -0,0 +1,81 @@
+package plc
+
+import (
+	"context"
+)
+
+// ContextReader is the interface that wraps the ReadTagContext method.
+type ContextReader interface {
+	// ReadTagContext reads the requested tag into the provided value.
+	// If ctx is done before the read completes, the read is abandoned and ctx.Err() is returned.
+	ReadTagContext(ctx context.Context, name string, value interface{}) error
+}
+
+// ContextWriter is the interface that wraps the WriteTagContext method.
+type ContextWriter interface {
+	// WriteTagContext writes the provided tag and value.
+	// If ctx is done before the write completes, the write is abandoned and ctx.Err() is returned.
+	// Note that an abandoned write may or may not have reached the PLC.
+	WriteTagContext(ctx context.Context, name string, value interface{}) error
+}
+
+type ContextReadWriter interface {
+	ContextReader
+	ContextWriter
+}
+
+// NewContextReader lifts a Reader into a ContextReader.
+// If rd already implements ContextReader, it is returned unchanged. Otherwise the returned
+// ContextReader checks the context before calling ReadTag, but it cannot interrupt a read
+// once it has started.
+func NewContextReader(rd Reader) ContextReader {
+	if crd, ok := rd.(ContextReader); ok {
+		return crd
+	}
+	return contextReader{rd}
+}
+
+// NewContextWriter lifts a Writer into a ContextWriter.
+// If wr already implements ContextWriter, it is returned unchanged. Otherwise the returned
+// ContextWriter checks the context before calling WriteTag, but it cannot interrupt a write
+// once it has started.
+func NewContextWriter(wr Writer) ContextWriter {
+	if cwr, ok := wr.(ContextWriter); ok {
+		return cwr
+	}
+	return contextWriter{wr}
+}
+
+// NewContextReadWriter lifts a ReadWriter into a ContextReadWriter.
+// See NewContextReader and NewContextWriter for details.
+func NewContextReadWriter(rw ReadWriter) ContextReadWriter {
+	if crw, ok := rw.(ContextReadWriter); ok {
+		return crw
+	}
+	return struct {
+		ContextReader
+		ContextWriter
+	}{NewContextReader(rw), NewContextWriter(rw)}
+}
+
+type contextReader struct {
+	Reader
+}
+
+func (rd contextReader) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return rd.Reader.ReadTag(name, value)
+}
+
+type contextWriter struct {
+	Writer
+}
+
+func (wr contextWriter) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return wr.Writer.WriteTag(name, value)
+}
@@ -0,0 +1,138 @@
+package plc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contextFakeReadWriter records the context it was called with.
+type contextFakeReadWriter struct {
+	FakeReadWriter
+	ctx context.Context
+}
+
+func (rw *contextFakeReadWriter) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	rw.ctx = ctx
+	return rw.FakeReadWriter.ReadTag(name, value)
+}
+
+func (rw *contextFakeReadWriter) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	rw.ctx = ctx
+	return rw.FakeReadWriter.WriteTag(name, value)
+}
+
+type testContextKey struct{}
+
+func TestNewContextReaderKeepsContextReader(t *testing.T) {
+	rw := &contextFakeReadWriter{FakeReadWriter: FakeReadWriter{}}
+	assert.Equal(t, ContextReader(rw), NewContextReader(rw))
+	assert.Equal(t, ContextWriter(rw), NewContextWriter(rw))
+	assert.Equal(t, ContextReadWriter(rw), NewContextReadWriter(rw))
+}
+
+func TestNewContextReaderPassesThrough(t *testing.T) {
+	fakeRW := FakeReadWriter{testTagName: 7}
+
+	var actual int
+	err := NewContextReader(fakeRW).ReadTagContext(context.Background(), testTagName, &actual)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, actual)
+
+	err = NewContextWriter(fakeRW).WriteTagContext(context.Background(), testTagName, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, fakeRW[testTagName])
+}
+
+func TestNewContextReadWriterCancelled(t *testing.T) {
+	fakeRW := FakeReadWriter{testTagName: 7}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var actual int
+	err := NewContextReadWriter(fakeRW).ReadTagContext(ctx, testTagName, &actual)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, actual, "Cancelled read should not have read anything")
+
+	err = NewContextReadWriter(fakeRW).WriteTagContext(ctx, testTagName, 8)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 7, fakeRW[testTagName], "Cancelled write should not have written anything")
+}
+
+func TestContextIsPassedDownstream(t *testing.T) {
+	ctx := context.WithValue(context.Background(), testContextKey{}, "value")
+	rw := &contextFakeReadWriter{FakeReadWriter: FakeReadWriter{testTagName: 7}}
+
+	readers := map[string]ContextReader{
+		"Pooled":      NewPooled(rw, 1),
+		"Cache":       NewCache(rw),
+		"Refresher":   NewRefresher(rw, time.Hour),
+		"SplitReader": NewSplitReader(rw),
+		"TagLocker":   NewTagLocker(rw),
+	}
+	for name, rd := range readers {
+		t.Run(name, func(tt *testing.T) {
+			rw.ctx = nil
+			var actual int
+			err := rd.ReadTagContext(ctx, testTagName, &actual)
+			require.NoError(tt, err)
+			assert.Equal(tt, 7, actual)
+			assert.Equal(tt, ctx, rw.ctx)
+		})
+	}
+
+	writers := map[string]ContextWriter{
+		"Pooled":      NewPooled(rw, 1),
+		"SplitWriter": NewSplitWriter(rw),
+		"TagLocker":   NewTagLocker(rw),
+	}
+	for name, wr := range writers {
+		t.Run(name, func(tt *testing.T) {
+			rw.ctx = nil
+			err := wr.WriteTagContext(ctx, testTagName, 8)
+			require.NoError(tt, err)
+			assert.Equal(tt, ctx, rw.ctx)
+		})
+	}
+}
+
+func TestPooledContextTimeoutWhileQueued(t *testing.T) {
+	fakeRW := FakeReadWriter{testTagName: 7}
+	p := NewPooled(fakeRW, 0) // No workers, so everything waits in the queue forever
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var actual int
+	err := p.ReadTagContext(ctx, testTagName, &actual)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestTagLockerContextTimeoutWhileLocked(t *testing.T) {
+	tl := NewTagLocker(FakeReadWriter{testTagName: 7})
+
+	// Hold the write lock so the read can't proceed
+	components := []string{testTagName}
+	require.NoError(t, tl.tagTree.lock(components))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var actual int
+	err := tl.ReadTagContext(ctx, testTagName, &actual)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// Once the lock is released, the abandoned read lock must be released too, so a write can proceed
+	require.NoError(t, tl.tagTree.unlock(components))
+	done := make(chan error)
+	go func() { done <- tl.WriteTag(testTagName, 8) }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "Abandoned read lock was never released")
+	}
+}
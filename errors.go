@@ -1,9 +1,11 @@
 package plc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 var (
@@ -11,6 +13,10 @@ var (
 	ErrPlcInternal   = errors.New("Internal PLC error")
 	ErrPlcConnection = errors.New("PLC connection error")
 	Pending          = errors.New("The PLC has not yet provided a result for the non-blocking request")
+	ErrClosed        = errors.New("Operation attempted after Close")
+	ErrQueueFull     = errors.New("The work queue is full")
+	ErrCircuitOpen   = errors.New("The circuit breaker is open, so the PLC was not contacted")
+	ErrStale         = errors.New("The cached value is stale")
 )
 
 type ErrNonPointerRead struct {
@@ -23,3 +29,75 @@ func (err ErrNonPointerRead) Error() string {
 }
 
 func (err ErrNonPointerRead) Unwrap() error { return ErrBadRequest } // Even though we don't say "bad request", that's still this error's type
+
+// OpError describes an operation on a tag which failed. It unwraps to the underlying error,
+// so errors.Is still works with ErrBadRequest, ErrPlcConnection, and ErrPlcInternal.
+type OpError struct {
+	Op      string // The operation which failed, e.g. "ReadTag", "WriteTag", or "ReadRange"
+	TagName string // The tag the operation was for
+	Layer   string // The type which reported the error, e.g. "Device" or "Cache"
+	Err     error
+}
+
+func (err OpError) Error() string {
+	return fmt.Sprintf("%s %s '%s': %v", err.Layer, err.Op, err.TagName, err.Err)
+}
+
+func (err OpError) Unwrap() error { return err.Err }
+
+// WrapOpError returns err wrapped in an OpError, unless err is nil or already contains an OpError.
+// That way, the reported tag and layer are from the layer closest to the PLC, which has the most
+// specific information. Context errors are returned unchanged, since ContextReader and
+// ContextWriter promise to return ctx.Err().
+func WrapOpError(err error, layer, op, tagName string) error {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return err
+	}
+	if errors.As(err, &OpError{}) {
+		return err
+	}
+	return OpError{Op: op, TagName: tagName, Layer: layer, Err: err}
+}
+
+// MultiError holds every error from an operation on several tags which continued after the first error.
+type MultiError []error
+
+func (errs MultiError) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors occurred: %s", len(errs), strings.Join(msgs, "; "))
+}
+
+// Is returns true if any of the errors is target.
+func (errs MultiError) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors which matches target.
+func (errs MultiError) As(target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// TagNames returns the name of the tag for each error which is an OpError.
+func (errs MultiError) TagNames() []string {
+	var names []string
+	for _, err := range errs {
+		var opErr OpError
+		if errors.As(err, &opErr) {
+			names = append(names, opErr.TagName)
+		}
+	}
+	return names
+}
@@ -0,0 +1,55 @@
+package plc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapOpError(t *testing.T) {
+	assert.Nil(t, WrapOpError(nil, "Layer", "ReadTag", testTagName))
+	assert.Equal(t, context.Canceled, WrapOpError(context.Canceled, "Layer", "ReadTag", testTagName))
+	assert.Equal(t, context.DeadlineExceeded, WrapOpError(context.DeadlineExceeded, "Layer", "ReadTag", testTagName))
+
+	err := WrapOpError(ErrPlcConnection, "Inner", "ReadTag", testTagName+".field")
+	assert.Equal(t, OpError{Op: "ReadTag", TagName: testTagName + ".field", Layer: "Inner", Err: ErrPlcConnection}, err)
+	assert.True(t, errors.Is(err, ErrPlcConnection))
+	assert.Equal(t, "Inner ReadTag 'TEST_TAG.field': PLC connection error", err.Error())
+
+	assert.Equal(t, err, WrapOpError(err, "Outer", "ReadTag", testTagName), "The innermost OpError should be kept")
+}
+
+func TestOpErrorThroughStack(t *testing.T) {
+	type testStruct struct {
+		Good int
+		Bad  int
+	}
+	rw := FakeReadWriter{testTagName + ".Good": 1}
+	rd := NewCache(NewTagLocker(NewPooled(rw, 1)))
+
+	var actual testStruct
+	err := NewSplitReader(rd).ReadTag(testTagName, &actual)
+	require.Error(t, err)
+
+	var opErr OpError
+	require.True(t, errors.As(err, &opErr))
+	assert.Equal(t, "ReadTag", opErr.Op)
+	assert.Equal(t, testTagName+".Bad", opErr.TagName, "The tag should be the field which failed")
+	assert.Equal(t, "Pooled", opErr.Layer)
+}
+
+func TestMultiError(t *testing.T) {
+	err := MultiError{
+		WrapOpError(ErrBadRequest, "Layer", "ReadTag", "A"),
+		errors.New("Other"),
+		WrapOpError(ErrPlcConnection, "Layer", "ReadTag", "B"),
+	}
+	assert.Equal(t, "3 errors occurred: Layer ReadTag 'A': Invalid request; Other; Layer ReadTag 'B': PLC connection error", err.Error())
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	assert.True(t, errors.Is(err, ErrPlcConnection))
+	assert.False(t, errors.Is(err, ErrPlcInternal))
+	assert.Equal(t, []string{"A", "B"}, err.TagNames())
+}
@@ -3,10 +3,11 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"os"
 	"time"
 
 	"github.com/stellentus/go-plc"
-	"github.com/stellentus/go-plc/example"
 	"github.com/stellentus/go-plc/libplctag"
 )
 
@@ -32,28 +33,22 @@ func main() {
 		panicIfError(err, "Close was unsuccessful")
 	}()
 
-	// Wrap with debug
-	rw := plc.ReadWriter(example.DebugPrinter{
-		ReadPrefix: "READ",
-		Reader:     device,
-		Writer:     device,
-		DebugFunc:  fmt.Printf,
-	})
+	// Wrap with logging
+	logger := plc.NewStdLogger(log.New(os.Stdout, "", 0))
+	rw := plc.ReadWriter(plc.NewLogging(device, logger, plc.LogName("READ")))
 
 	if *numWorkers > 0 {
 		fmt.Printf("Creating a pool of %d threads\n", *numWorkers)
-		rw = plc.NewPooled(rw, *numWorkers)
+		pooled := plc.NewPooled(rw, *numWorkers)
+		defer pooled.Close()
+		rw = pooled
 	}
 
 	fmt.Printf("Creating a cache\n")
 	directReader := plc.NewCache(rw) // Reads the device directly
 
 	// cacheReader only reads out of the cache
-	cacheReader := example.DebugPrinter{
-		ReadPrefix: "CACHE-READ",
-		Reader:     directReader.CacheReader(),
-		DebugFunc:  fmt.Printf,
-	}
+	cacheReader := plc.NewLoggingReader(directReader.CacheReader(), logger, plc.LogName("CACHE-READ"))
 
 	// Get the first read
 	val := uint8(0)
@@ -70,6 +65,12 @@ func main() {
 	directReader.ReadTag(*tagName, &val)
 	cacheReader.ReadTag(*tagName, &val)
 
+	// A WriteThroughCache updates the cache when a value is written through it.
+	writeThrough := plc.NewWriteThroughCache(rw)
+	writeThrough.ReadTag(*tagName, &val)
+	writeThrough.WriteTag(*tagName, val+1)
+	plc.NewLoggingReader(writeThrough.CacheReader(), logger, plc.LogName("WRITE-THROUGH-CACHE-READ")).ReadTag(*tagName, &val)
+
 	// Now return to the original value.
 	rw.WriteTag(*tagName, original)
 }
@@ -8,6 +8,9 @@ import (
 
 type DebugFunc func(string, ...interface{}) (int, error)
 
+// DebugPrinter prints every read and write with DebugFunc.
+//
+// Deprecated: Use plc.Logging, which supports levels, sampling, and any logger.
 type DebugPrinter struct {
 	ReadPrefix string
 	plc.Reader
@@ -3,10 +3,11 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"os"
 	"time"
 
 	"github.com/stellentus/go-plc"
-	"github.com/stellentus/go-plc/example"
 	"github.com/stellentus/go-plc/libplctag"
 )
 
@@ -37,25 +38,21 @@ func main() {
 		panicIfError(err, "Close was unsuccessful")
 	}()
 
-	// Wrap with debug
-	rw := plc.ReadWriter(example.DebugPrinter{
-		ReadPrefix: "READ",
-		Reader:     device,
-		Writer:     device,
-		DebugFunc:  fmt.Printf,
-	})
+	// Wrap with logging
+	logger := plc.NewStdLogger(log.New(os.Stdout, "", 0))
+	rw := plc.ReadWriter(plc.NewLogging(device, logger, plc.LogName("READ")))
 
 	if *numWorkers > 0 {
 		fmt.Printf("Creating a pool of %d threads\n", *numWorkers)
-		rw = plc.NewPooled(rw, *numWorkers)
+		pooled := plc.NewPooled(rw, *numWorkers)
+		defer pooled.Close()
+		rw = pooled
 	}
 
 	fmt.Printf("Creating a refresher to reload every %v\n", *refreshDuration)
-	refresher := example.DebugPrinter{ // Wrap the referesher in debug
-		ReadPrefix: "REFRESH-START",
-		Reader:     plc.NewRefresher(rw, *refreshDuration),
-		DebugFunc:  fmt.Printf,
-	}
+	plcRefresher := plc.NewRefresher(rw, *refreshDuration)
+	defer plcRefresher.Close()
+	refresher := plc.NewLoggingReader(plcRefresher, logger, plc.LogName("REFRESH-START")) // Wrap the refresher in logging
 
 	// Tell the refresher to begin reading
 	val := uint8(0)
@@ -0,0 +1,265 @@
+package plc
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// FakeTagDatabase is a simulated PLC for use in tests. Unlike FakeReadWriter, it stores values
+// in a tree keyed by the components of each tag name, so a struct or array can be written as a
+// whole and then its fields or elements can be read individually, or vice versa.
+// Tags are stored by their TagPath segments, so "ARR[1,2]" and "ARR[1][2]" are different tags.
+// Numeric values are converted between kinds as long as the value fits.
+// A bit of an integer which was already written can be read or written as a bool.
+type FakeTagDatabase struct {
+	mutex sync.Mutex
+	root  fakeTagNode
+}
+
+var _ = ReadWriter(&FakeTagDatabase{}) // Compiler makes sure this type is a ReadWriter
+
+// fakeTagNode is either a leaf holding a value, or a struct or array with children.
+type fakeTagNode struct {
+	seg      TagSegment // The last segment of the node's path
+	value    interface{}
+	children map[string]*fakeTagNode // Keyed by the string of each child's segment
+}
+
+// NewFakeTagDatabase returns an empty FakeTagDatabase.
+func NewFakeTagDatabase() *FakeTagDatabase {
+	return &FakeTagDatabase{}
+}
+
+// ReadTag reads the named tag into value, which may be a struct, array, or slice of
+// values that were written separately.
+func (db *FakeTagDatabase) ReadTag(name string, value interface{}) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return NewSplitReader(readerFunc(db.readLeaf)).ReadTag(name, value)
+}
+
+// WriteTag writes the value to the named tag. A struct, array, or slice is stored as its
+// separate components, so each can be read on its own.
+func (db *FakeTagDatabase) WriteTag(name string, value interface{}) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return NewSplitWriter(writerFunc(db.writeLeaf)).WriteTag(name, value)
+}
+
+// TagNames returns the sorted names of all values in the database.
+func (db *FakeTagDatabase) TagNames() []string {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var names []string
+	var walk func(path TagPath, node *fakeTagNode)
+	walk = func(path TagPath, node *fakeTagNode) {
+		if node.children == nil {
+			names = append(names, path.String())
+			return
+		}
+		for _, child := range node.children {
+			walk(path.Child(child.seg), child)
+		}
+	}
+	if db.root.children != nil {
+		walk(nil, &db.root)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (db *FakeTagDatabase) readLeaf(name string, value interface{}) error {
+	path, err := ParseTagPath(name)
+	if err != nil {
+		return WrapOpError(fmt.Errorf("%w: %v", ErrBadRequest, err), "FakeTagDatabase", "ReadTag", name)
+	}
+	bit := path.Bit()
+
+	node := &db.root
+	for _, seg := range path.Word() {
+		node = node.children[seg.String()]
+		if node == nil {
+			return WrapOpError(fmt.Errorf("%w: tag does not exist", ErrBadRequest), "FakeTagDatabase", "ReadTag", name)
+		}
+	}
+	if node.children != nil {
+		return WrapOpError(fmt.Errorf("%w: tag is a struct or array, not a value", ErrBadRequest), "FakeTagDatabase", "ReadTag", name)
+	}
+
+	in := reflect.ValueOf(node.value)
+	if bit != NoBit {
+		word, err := bitWord(in, bit)
+		if err != nil {
+			return WrapOpError(err, "FakeTagDatabase", "ReadTag", name)
+		}
+		in = reflect.ValueOf(word&(1<<uint(bit)) != 0)
+	}
+
+	err = convertValue(reflect.ValueOf(value).Elem(), in)
+	return WrapOpError(err, "FakeTagDatabase", "ReadTag", name)
+}
+
+func (db *FakeTagDatabase) writeLeaf(name string, value interface{}) error {
+	path, err := ParseTagPath(name)
+	if err != nil {
+		return WrapOpError(fmt.Errorf("%w: %v", ErrBadRequest, err), "FakeTagDatabase", "WriteTag", name)
+	}
+	if value == nil {
+		return WrapOpError(fmt.Errorf("%w: cannot write a nil value", ErrBadRequest), "FakeTagDatabase", "WriteTag", name)
+	}
+	if bit := path.Bit(); bit != NoBit {
+		return WrapOpError(db.writeBit(path.Word(), bit, value), "FakeTagDatabase", "WriteTag", name)
+	}
+
+	node := &db.root
+	for _, seg := range path {
+		if node.children == nil {
+			node.children = map[string]*fakeTagNode{} // If it was a value, it's now a struct or array
+			node.value = nil
+		}
+		key := seg.String()
+		child := node.children[key]
+		if child == nil {
+			child = &fakeTagNode{seg: seg}
+			node.children[key] = child
+		}
+		node = child
+	}
+	node.value = value
+	node.children = nil // If it was a struct or array, it's now a value
+	return nil
+}
+
+// writeBit sets or clears a bit of an integer which was already written.
+func (db *FakeTagDatabase) writeBit(path TagPath, bit int, value interface{}) error {
+	set, ok := value.(bool)
+	if !ok {
+		return fmt.Errorf("%w: a bit must be written from a bool, not %T", ErrBadRequest, value)
+	}
+
+	node := &db.root
+	for _, seg := range path {
+		node = node.children[seg.String()]
+		if node == nil {
+			return fmt.Errorf("%w: tag does not exist", ErrBadRequest)
+		}
+	}
+	if node.children != nil {
+		return fmt.Errorf("%w: tag is a struct or array, not an integer", ErrBadRequest)
+	}
+
+	in := reflect.ValueOf(node.value)
+	word, err := bitWord(in, bit)
+	if err != nil {
+		return err
+	}
+	if set {
+		word |= 1 << uint(bit)
+	} else {
+		word &^= 1 << uint(bit)
+	}
+
+	out := reflect.New(in.Type()).Elem()
+	switch out.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		out.SetUint(word)
+	default:
+		out.SetInt(int64(word)) // Setting the top bit of a signed integer makes it negative
+	}
+	node.value = out.Interface()
+	return nil
+}
+
+// bitWord returns the bits of an integer value, after checking it contains the bit.
+func bitWord(in reflect.Value, bit int) (uint64, error) {
+	switch in.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if bit < in.Type().Bits() {
+			// Mask off the sign extension so it can be stored back into the same type
+			return uint64(in.Int()) & (math.MaxUint64 >> uint(64-in.Type().Bits())), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if bit < in.Type().Bits() {
+			return in.Uint(), nil
+		}
+	default:
+		return 0, fmt.Errorf("%w: cannot address a bit of %v", ErrBadRequest, in.Type())
+	}
+	return 0, fmt.Errorf("%w: bit %d is out of range for %v", ErrBadRequest, bit, in.Type())
+}
+
+// convertValue sets out to in, converting between numeric kinds if necessary.
+func convertValue(out, in reflect.Value) error {
+	if !in.IsValid() {
+		return fmt.Errorf("%w: cannot convert a nil value to %v", ErrBadRequest, out.Type())
+	}
+	if in.Type().AssignableTo(out.Type()) {
+		out.Set(in)
+		return nil
+	}
+
+	err := fmt.Errorf("%w: cannot convert %v (%v) to %v", ErrBadRequest, in.Interface(), in.Type(), out.Type())
+	switch out.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toInt64(in)
+		if !ok || out.OverflowInt(i) {
+			return err
+		}
+		out.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, ok := toUint64(in)
+		if !ok || out.OverflowUint(u) {
+			return err
+		}
+		out.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch in.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(in.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(in.Uint())
+		case reflect.Float32, reflect.Float64:
+			f = in.Float()
+		default:
+			return err
+		}
+		if out.OverflowFloat(f) {
+			return err
+		}
+		out.SetFloat(f)
+	default:
+		return err
+	}
+	return nil
+}
+
+// toInt64 returns the numeric value as an int64, if it is an integer which fits.
+func toInt64(in reflect.Value) (int64, bool) {
+	switch in.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return in.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(in.Uint()), in.Uint() <= math.MaxInt64
+	case reflect.Float32, reflect.Float64:
+		f := in.Float()
+		return int64(f), f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64
+	default:
+		return 0, false
+	}
+}
+
+// toUint64 returns the numeric value as a uint64, if it is a non-negative integer which fits.
+func toUint64(in reflect.Value) (uint64, bool) {
+	switch in.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return in.Uint(), true
+	default:
+		i, ok := toInt64(in)
+		return uint64(i), ok && i >= 0
+	}
+}
@@ -0,0 +1,161 @@
+package plc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDBMotor struct {
+	Speed   float32
+	Running bool
+	Faults  [3]int16
+}
+
+func TestFakeTagDatabaseStructAndFields(t *testing.T) {
+	db := NewFakeTagDatabase()
+	require.NoError(t, db.WriteTag("Motor", fakeDBMotor{Speed: 12.5, Running: true, Faults: [3]int16{1, 2, 3}}))
+
+	assert.Equal(t, []string{"Motor.Faults[0]", "Motor.Faults[1]", "Motor.Faults[2]", "Motor.Running", "Motor.Speed"}, db.TagNames())
+
+	var speed float32
+	require.NoError(t, db.ReadTag("Motor.Speed", &speed))
+	assert.Equal(t, float32(12.5), speed)
+
+	var fault int16
+	require.NoError(t, db.ReadTag("Motor.Faults[1]", &fault))
+	assert.Equal(t, int16(2), fault)
+
+	// Write a single element, then read the whole struct
+	require.NoError(t, db.WriteTag("Motor.Faults[2]", int16(9)))
+	var motor fakeDBMotor
+	require.NoError(t, db.ReadTag("Motor", &motor))
+	assert.Equal(t, fakeDBMotor{Speed: 12.5, Running: true, Faults: [3]int16{1, 2, 9}}, motor)
+}
+
+func TestFakeTagDatabaseFieldsThenStruct(t *testing.T) {
+	db := NewFakeTagDatabase()
+	require.NoError(t, db.WriteTag("Motor.Speed", float32(3)))
+	require.NoError(t, db.WriteTag("Motor.Running", false))
+	require.NoError(t, db.WriteTag("Motor.Faults", []int16{4, 5, 6}))
+
+	var motor fakeDBMotor
+	require.NoError(t, db.ReadTag("Motor", &motor))
+	assert.Equal(t, fakeDBMotor{Speed: 3, Faults: [3]int16{4, 5, 6}}, motor)
+}
+
+func TestFakeTagDatabaseConvertsNumbers(t *testing.T) {
+	db := NewFakeTagDatabase()
+	require.NoError(t, db.WriteTag("Small", 7))
+	require.NoError(t, db.WriteTag("Negative", int8(-1)))
+	require.NoError(t, db.WriteTag("Fraction", 1.5))
+
+	var u8 uint8
+	require.NoError(t, db.ReadTag("Small", &u8))
+	assert.Equal(t, uint8(7), u8)
+
+	var f64 float64
+	require.NoError(t, db.ReadTag("Negative", &f64))
+	assert.Equal(t, -1.0, f64)
+
+	tests := map[string]interface{}{
+		"Negative": &u8,       // Doesn't fit in unsigned
+		"Fraction": new(int),  // Not an integer
+		"Small":    new(bool), // Not numeric
+	}
+	for name, value := range tests {
+		err := db.ReadTag(name, value)
+		assert.True(t, errors.Is(err, ErrBadRequest), "Reading %s into %T should fail", name, value)
+	}
+
+	require.NoError(t, db.WriteTag("Big", 300))
+	assert.Error(t, db.ReadTag("Big", &u8), "300 doesn't fit in uint8")
+}
+
+func TestFakeTagDatabaseErrors(t *testing.T) {
+	db := NewFakeTagDatabase()
+	require.NoError(t, db.WriteTag("Motor", fakeDBMotor{}))
+
+	var unused int
+	err := db.ReadTag("Missing", &unused)
+	var opErr OpError
+	require.True(t, errors.As(err, &opErr))
+	assert.Equal(t, "FakeTagDatabase", opErr.Layer)
+	assert.Equal(t, "Missing", opErr.TagName)
+
+	assert.True(t, errors.Is(db.ReadTag("Motor", &unused), ErrBadRequest), "A struct can't be read into an int")
+	assert.True(t, errors.Is(db.ReadTag("Motor[", &unused), ErrBadRequest), "Invalid names should fail")
+
+	// Overwriting a struct with a value replaces it
+	require.NoError(t, db.WriteTag("Motor", 5))
+	require.NoError(t, db.ReadTag("Motor", &unused))
+	assert.Equal(t, 5, unused)
+	assert.Equal(t, []string{"Motor"}, db.TagNames())
+}
+
+type fakeDBStatus struct {
+	Word    int32
+	Running bool `plctag:"Word,bit=0"`
+	Faulted bool `plctag:"Word,bit=31"`
+}
+
+func TestFakeTagDatabaseBits(t *testing.T) {
+	db := NewFakeTagDatabase()
+	require.NoError(t, db.WriteTag("Status", int32(0x09)))
+
+	var bit bool
+	require.NoError(t, db.ReadTag("Status.3", &bit))
+	assert.True(t, bit)
+	require.NoError(t, db.ReadTag("Status.2", &bit))
+	assert.False(t, bit)
+
+	require.NoError(t, db.WriteTag("Status.31", true))
+	require.NoError(t, db.WriteTag("Status.0", false))
+	var word int32
+	require.NoError(t, db.ReadTag("Status", &word))
+	assert.Equal(t, int32(-0x7FFFFFF8), word)
+
+	err := db.ReadTag("Status.32", &bit)
+	assert.True(t, errors.Is(err, ErrBadRequest), "Bit should be out of range: %v", err)
+	err = db.WriteTag("Missing.1", true)
+	assert.True(t, errors.Is(err, ErrBadRequest), "Bit of a missing tag can't be written: %v", err)
+	err = db.WriteTag("Status.1", 1)
+	assert.True(t, errors.Is(err, ErrBadRequest), "Bit must be written from a bool: %v", err)
+}
+
+func TestFakeTagDatabaseBitStructTags(t *testing.T) {
+	db := NewFakeTagDatabase()
+	require.NoError(t, db.WriteTag("Motor.Word", int32(1)))
+
+	var status fakeDBStatus
+	require.NoError(t, db.ReadTag("Motor", &status))
+	assert.Equal(t, fakeDBStatus{Word: 1, Running: true}, status)
+
+	require.NoError(t, db.WriteTag("Motor", fakeDBStatus{Word: 4, Faulted: true}))
+	require.NoError(t, db.ReadTag("Motor", &status))
+	assert.Equal(t, fakeDBStatus{Word: -0x7FFFFFFC, Faulted: true}, status)
+}
+
+func TestFakeTagDatabaseNil(t *testing.T) {
+	db := NewFakeTagDatabase()
+	err := db.WriteTag("A", (*int)(nil))
+	assert.True(t, errors.Is(err, ErrBadRequest), "A nil pointer can't be written: %v", err)
+	err = db.WriteTag("A", nil)
+	assert.True(t, errors.Is(err, ErrBadRequest), "A nil value can't be written: %v", err)
+	assert.Empty(t, db.TagNames())
+}
+
+func TestFakeTagDatabaseMultiDimensional(t *testing.T) {
+	db := NewFakeTagDatabase()
+	require.NoError(t, db.WriteTag("Grid[1,2]", 7))
+	require.NoError(t, db.WriteTag("Nested[1][2]", 8))
+	assert.Equal(t, []string{"Grid[1,2]", "Nested[1][2]"}, db.TagNames())
+
+	var val int
+	require.NoError(t, db.ReadTag("Grid[1,2]", &val))
+	assert.Equal(t, 7, val)
+	assert.True(t, errors.Is(db.ReadTag("Grid[1][2]", &val), ErrBadRequest), "A multi-dimensional index is not a nested index")
+	assert.True(t, errors.Is(db.ReadTag("Nested[1,2]", &val), ErrBadRequest), "A nested index is not a multi-dimensional index")
+}
@@ -0,0 +1,179 @@
+package plc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// FaultRule describes the faults a FaultInjector introduces for matching operations.
+// The rates are probabilities from 0 to 1. At most one error is injected per operation.
+type FaultRule struct {
+	Op string // "ReadTag" or "WriteTag", or empty to match both
+
+	Latency       time.Duration // Every operation is delayed by at least this much
+	LatencyJitter time.Duration // A random additional delay, up to this much
+
+	ConnectionErrorRate float64 // Fail with ErrPlcConnection
+	InternalErrorRate   float64 // Fail with ErrPlcInternal
+	BadRequestRate      float64 // Fail with ErrBadRequest
+
+	// TimeoutRate is the probability that an operation hangs for Timeout and then fails with
+	// ErrPlcConnection, as if the PLC stopped responding. If the context is done first, the
+	// context's error is returned instead.
+	TimeoutRate float64
+	Timeout     time.Duration
+}
+
+// FaultInjector wraps another ReadWriter and injects latency and errors, so retry, cache,
+// and circuit breaker logic can be tested without a misbehaving PLC. Operations which aren't
+// failed are passed through.
+//
+// Random decisions use a seeded source, so a sequential test is reproducible.
+type FaultInjector struct {
+	plc ReadWriter
+	now func() time.Time
+
+	mutex        sync.Mutex
+	rules        []faultRule
+	rand         *rand.Rand
+	offlineUntil time.Time
+}
+
+var _ = ReadWriter(&FaultInjector{})        // Compiler makes sure this type is a ReadWriter
+var _ = ContextReadWriter(&FaultInjector{}) // Compiler makes sure this type is a ContextReadWriter
+
+type faultRule struct {
+	pattern *regexp.Regexp
+	FaultRule
+}
+
+// fault is the outcome chosen for one operation.
+type fault struct {
+	delay time.Duration
+	err   error
+}
+
+// NewFaultInjector returns a FaultInjector with no rules, so it passes everything through to plc.
+func NewFaultInjector(plc ReadWriter, seed int64) *FaultInjector {
+	return &FaultInjector{
+		plc:  plc,
+		now:  time.Now,
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// AddRule adds a rule for tags whose names match the regular expression. An empty pattern matches
+// all tags. Only the first matching rule applies to an operation, so specific rules should be
+// added before general ones.
+func (fi *FaultInjector) AddRule(pattern string, rule FaultRule) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("%w: FaultInjector pattern: %v", ErrBadRequest, err)
+	}
+
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.rules = append(fi.rules, faultRule{re, rule})
+	return nil
+}
+
+// ClearRules removes all rules.
+func (fi *FaultInjector) ClearRules() {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.rules = nil
+}
+
+// GoOffline makes every operation fail immediately with ErrPlcConnection for the duration,
+// as if the PLC had been disconnected.
+func (fi *FaultInjector) GoOffline(duration time.Duration) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.offlineUntil = fi.now().Add(duration)
+}
+
+// GoOnline ends any offline period started by GoOffline.
+func (fi *FaultInjector) GoOnline() {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.offlineUntil = time.Time{}
+}
+
+func (fi *FaultInjector) ReadTag(name string, value interface{}) error {
+	return fi.ReadTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext reads the tag unless a fault is injected.
+func (fi *FaultInjector) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	if err := fi.inject(ctx, "ReadTag", name); err != nil {
+		return err
+	}
+	return NewContextReader(fi.plc).ReadTagContext(ctx, name, value)
+}
+
+func (fi *FaultInjector) WriteTag(name string, value interface{}) error {
+	return fi.WriteTagContext(context.Background(), name, value)
+}
+
+// WriteTagContext writes the tag unless a fault is injected.
+func (fi *FaultInjector) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	if err := fi.inject(ctx, "WriteTag", name); err != nil {
+		return err
+	}
+	return NewContextWriter(fi.plc).WriteTagContext(ctx, name, value)
+}
+
+// inject waits for any injected latency, then returns the injected error, if any.
+func (fi *FaultInjector) inject(ctx context.Context, op, name string) error {
+	f := fi.choose(op, name)
+	if f.delay > 0 {
+		timer := time.NewTimer(f.delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	return WrapOpError(f.err, "FaultInjector", op, name)
+}
+
+// choose decides which fault to inject for the operation.
+func (fi *FaultInjector) choose(op, name string) fault {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+
+	if fi.now().Before(fi.offlineUntil) {
+		return fault{err: fmt.Errorf("%w: injected fault: PLC is offline", ErrPlcConnection)}
+	}
+
+	for _, rule := range fi.rules {
+		if (rule.Op != "" && rule.Op != op) || !rule.pattern.MatchString(name) {
+			continue
+		}
+
+		f := fault{delay: rule.Latency}
+		if rule.LatencyJitter > 0 {
+			f.delay += time.Duration(fi.rand.Int63n(int64(rule.LatencyJitter) + 1))
+		}
+
+		random := fi.rand.Float64()
+		switch {
+		case random < rule.ConnectionErrorRate:
+			f.err = fmt.Errorf("%w: injected fault", ErrPlcConnection)
+		case random < rule.ConnectionErrorRate+rule.InternalErrorRate:
+			f.err = fmt.Errorf("%w: injected fault", ErrPlcInternal)
+		case random < rule.ConnectionErrorRate+rule.InternalErrorRate+rule.BadRequestRate:
+			f.err = fmt.Errorf("%w: injected fault", ErrBadRequest)
+		case random < rule.ConnectionErrorRate+rule.InternalErrorRate+rule.BadRequestRate+rule.TimeoutRate:
+			f.delay += rule.Timeout
+			f.err = fmt.Errorf("%w: injected fault: timed out after %v", ErrPlcConnection, rule.Timeout)
+		}
+		return f
+	}
+	return fault{}
+}
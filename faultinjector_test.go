@@ -0,0 +1,127 @@
+package plc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjectorPassesThrough(t *testing.T) {
+	fi := NewFaultInjector(FakeReadWriter{testTagName: 7}, 1)
+
+	var actual int
+	require.NoError(t, fi.ReadTag(testTagName, &actual))
+	assert.Equal(t, 7, actual)
+	assert.NoError(t, fi.WriteTag(testTagName, 8))
+}
+
+func TestFaultInjectorSentinels(t *testing.T) {
+	tests := map[string]struct {
+		rule     FaultRule
+		sentinel error
+	}{
+		"Connection": {FaultRule{ConnectionErrorRate: 1}, ErrPlcConnection},
+		"Internal":   {FaultRule{InternalErrorRate: 1}, ErrPlcInternal},
+		"BadRequest": {FaultRule{BadRequestRate: 1}, ErrBadRequest},
+		"Timeout":    {FaultRule{TimeoutRate: 1, Timeout: time.Millisecond}, ErrPlcConnection},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fi := NewFaultInjector(FakeReadWriter{testTagName: 7, "OTHER": 3}, 1)
+			require.NoError(t, fi.AddRule("^TEST_", test.rule))
+
+			var actual int
+			err := fi.ReadTag(testTagName, &actual)
+			assert.True(t, errors.Is(err, test.sentinel), "Wrong error: %v", err)
+			var opErr OpError
+			require.True(t, errors.As(err, &opErr))
+			assert.Equal(t, "FaultInjector", opErr.Layer)
+
+			assert.NoError(t, fi.ReadTag("OTHER", &actual), "Rule shouldn't match other tags")
+		})
+	}
+}
+
+func TestFaultInjectorFirstMatchingRule(t *testing.T) {
+	fi := NewFaultInjector(FakeReadWriter{testTagName: 7}, 1)
+	require.NoError(t, fi.AddRule("", FaultRule{Op: "WriteTag", InternalErrorRate: 1}))
+	require.NoError(t, fi.AddRule("", FaultRule{BadRequestRate: 1}))
+
+	err := fi.WriteTag(testTagName, 1)
+	assert.True(t, errors.Is(err, ErrPlcInternal), "Wrong error: %v", err)
+	var actual int
+	err = fi.ReadTag(testTagName, &actual)
+	assert.True(t, errors.Is(err, ErrBadRequest), "Wrong error: %v", err)
+
+	fi.ClearRules()
+	assert.NoError(t, fi.ReadTag(testTagName, &actual))
+	assert.Error(t, fi.AddRule("[", FaultRule{}))
+}
+
+func TestFaultInjectorIsReproducible(t *testing.T) {
+	results := func() []bool {
+		fi := NewFaultInjector(FakeReadWriter{testTagName: 7}, 42)
+		require.NoError(t, fi.AddRule("", FaultRule{ConnectionErrorRate: 0.5}))
+		failed := make([]bool, 50)
+		var actual int
+		for i := range failed {
+			failed[i] = fi.ReadTag(testTagName, &actual) != nil
+		}
+		return failed
+	}
+
+	first := results()
+	assert.Equal(t, first, results())
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+}
+
+func TestFaultInjectorOffline(t *testing.T) {
+	fi := NewFaultInjector(FakeReadWriter{testTagName: 7}, 1)
+	clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fi.now = clock.now
+
+	fi.GoOffline(10 * time.Second)
+	var actual int
+	err := fi.ReadTag(testTagName, &actual)
+	assert.True(t, errors.Is(err, ErrPlcConnection), "Wrong error: %v", err)
+
+	clock.Time = clock.Add(10 * time.Second)
+	assert.NoError(t, fi.ReadTag(testTagName, &actual))
+
+	fi.GoOffline(time.Hour)
+	fi.GoOnline()
+	assert.NoError(t, fi.ReadTag(testTagName, &actual))
+}
+
+func TestFaultInjectorLatencyAndContext(t *testing.T) {
+	fi := NewFaultInjector(FakeReadWriter{testTagName: 7}, 1)
+	require.NoError(t, fi.AddRule("", FaultRule{Latency: 5 * time.Millisecond, LatencyJitter: time.Millisecond}))
+
+	var actual int
+	start := time.Now()
+	require.NoError(t, fi.ReadTag(testTagName, &actual))
+	assert.True(t, time.Since(start) >= 5*time.Millisecond)
+
+	fi.ClearRules()
+	require.NoError(t, fi.AddRule("", FaultRule{TimeoutRate: 1, Timeout: time.Hour}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, fi.ReadTagContext(ctx, testTagName, &actual))
+}
+
+func TestFaultInjectorWithRetrying(t *testing.T) {
+	fi := NewFaultInjector(FakeReadWriter{testTagName: 7}, 3)
+	require.NoError(t, fi.AddRule("", FaultRule{ConnectionErrorRate: 0.3}))
+	r := newFastRetrying(fi, RetryAttempts(10))
+
+	for i := 0; i < 20; i++ {
+		var actual int
+		require.NoError(t, r.ReadTag(testTagName, &actual))
+		assert.Equal(t, 7, actual)
+	}
+}
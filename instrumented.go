@@ -0,0 +1,168 @@
+package plc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Observation describes a single operation which passed through an Instrumented.
+type Observation struct {
+	Layer      string // The name of the Instrumented, if one was set with InstrumentLayer
+	Op         string // "ReadTag" or "WriteTag"
+	TagName    string // Only set if InstrumentPerTag was provided
+	ErrorClass string // The result of ErrorClass, which is empty if the operation succeeded
+	Duration   time.Duration
+}
+
+// Metrics is the interface which receives measurements from an Instrumented.
+// Its methods may be called concurrently.
+type Metrics interface {
+	// Observe records a completed operation.
+	Observe(Observation)
+
+	// RegisterGauge registers a function which returns the current value of a gauge.
+	// It replaces any gauge already registered with the same layer and name.
+	RegisterGauge(layer, name string, value func() float64)
+}
+
+// ErrorClass returns a short name for the type of error, so errors can be counted by type.
+// It returns an empty string if err is nil.
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return "context"
+	case errors.Is(err, ErrBadRequest):
+		return "bad_request"
+	case errors.Is(err, ErrPlcConnection):
+		return "connection"
+	case errors.Is(err, ErrPlcInternal):
+		return "internal"
+	case errors.Is(err, ErrClosed):
+		return "closed"
+	case errors.Is(err, ErrQueueFull):
+		return "queue_full"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	default:
+		return "other"
+	}
+}
+
+// Instrumented wraps a Reader and Writer and reports every operation to a Metrics.
+// If the wrapped Reader is a Pooled or Refresher, it also registers gauges for its queue
+// depth or number of tags.
+type Instrumented struct {
+	reader  Reader
+	writer  Writer
+	metrics Metrics
+	layer   string
+	perTag  bool
+}
+
+var _ = ReadWriter(Instrumented{})        // Compiler makes sure this type is a ReadWriter
+var _ = ContextReadWriter(Instrumented{}) // Compiler makes sure this type is a ContextReadWriter
+
+// InstrumentedOption configures an Instrumented.
+type InstrumentedOption interface {
+	apply(*Instrumented)
+}
+
+// instrumentedOptionFunc wraps a func so it satisfies the InstrumentedOption interface.
+type instrumentedOptionFunc func(*Instrumented)
+
+func (f instrumentedOptionFunc) apply(in *Instrumented) { f(in) }
+
+// InstrumentLayer sets the Layer of every observation, so several Instrumenteds at different
+// layers of the stack can share a Metrics.
+func InstrumentLayer(layer string) InstrumentedOption {
+	return instrumentedOptionFunc(func(in *Instrumented) {
+		in.layer = layer
+	})
+}
+
+// InstrumentPerTag includes the tag name in every observation. Note that this might result in
+// a large number of metrics.
+func InstrumentPerTag() InstrumentedOption {
+	return instrumentedOptionFunc(func(in *Instrumented) {
+		in.perTag = true
+	})
+}
+
+// NewInstrumented returns an Instrumented which measures all operations on rw.
+func NewInstrumented(rw ReadWriter, metrics Metrics, opts ...InstrumentedOption) Instrumented {
+	return newInstrumented(rw, rw, metrics, opts)
+}
+
+// NewInstrumentedReader returns an Instrumented which measures reads from rd. It is for Readers
+// which aren't Writers, such as a Refresher. Writes to it fail with ErrBadRequest.
+func NewInstrumentedReader(rd Reader, metrics Metrics, opts ...InstrumentedOption) Instrumented {
+	return newInstrumented(rd, nil, metrics, opts)
+}
+
+func newInstrumented(rd Reader, wr Writer, metrics Metrics, opts []InstrumentedOption) Instrumented {
+	in := Instrumented{
+		reader:  rd,
+		writer:  wr,
+		metrics: metrics,
+	}
+	for _, opt := range opts {
+		opt.apply(&in)
+	}
+
+	switch rd := rd.(type) {
+	case Pooled:
+		registerPooledGauges(metrics, in.layer, rd)
+	case *Pooled:
+		registerPooledGauges(metrics, in.layer, *rd)
+	case *Refresher:
+		metrics.RegisterGauge(in.layer, "refresher_tags", func() float64 { return float64(rd.NumTags()) })
+	}
+	return in
+}
+
+func registerPooledGauges(metrics Metrics, layer string, p Pooled) {
+	metrics.RegisterGauge(layer, "pooled_queue_depth", func() float64 { return float64(p.QueueDepth()) })
+	metrics.RegisterGauge(layer, "pooled_workers", func() float64 { return float64(p.Workers()) })
+}
+
+func (in Instrumented) ReadTag(name string, value interface{}) error {
+	return in.ReadTagContext(context.Background(), name, value)
+}
+
+func (in Instrumented) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	start := time.Now()
+	err := NewContextReader(in.reader).ReadTagContext(ctx, name, value)
+	in.observe("ReadTag", name, time.Since(start), err)
+	return err
+}
+
+func (in Instrumented) WriteTag(name string, value interface{}) error {
+	return in.WriteTagContext(context.Background(), name, value)
+}
+
+func (in Instrumented) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	if in.writer == nil {
+		return WrapOpError(fmt.Errorf("%w: Instrumented has no Writer", ErrBadRequest), "Instrumented", "WriteTag", name)
+	}
+	start := time.Now()
+	err := NewContextWriter(in.writer).WriteTagContext(ctx, name, value)
+	in.observe("WriteTag", name, time.Since(start), err)
+	return err
+}
+
+func (in Instrumented) observe(op, name string, duration time.Duration, err error) {
+	obs := Observation{
+		Layer:      in.layer,
+		Op:         op,
+		ErrorClass: ErrorClass(err),
+		Duration:   duration,
+	}
+	if in.perTag {
+		obs.TagName = name
+	}
+	in.metrics.Observe(obs)
+}
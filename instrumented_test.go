@@ -0,0 +1,162 @@
+package plc
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMetrics stores every observation it receives.
+type recordingMetrics struct {
+	observations []Observation
+	gauges       map[string]func() float64
+}
+
+func (rm *recordingMetrics) Observe(obs Observation) {
+	obs.Duration = 0 // So observations can be compared
+	rm.observations = append(rm.observations, obs)
+}
+
+func (rm *recordingMetrics) RegisterGauge(layer, name string, value func() float64) {
+	if rm.gauges == nil {
+		rm.gauges = map[string]func() float64{}
+	}
+	rm.gauges[layer+"."+name] = value
+}
+
+func TestErrorClass(t *testing.T) {
+	assert.Equal(t, "", ErrorClass(nil))
+	assert.Equal(t, "bad_request", ErrorClass(WrapOpError(ErrBadRequest, "Layer", "ReadTag", testTagName)))
+	assert.Equal(t, "connection", ErrorClass(fmt.Errorf("timeout: %w", ErrPlcConnection)))
+	assert.Equal(t, "circuit_open", ErrorClass(ErrCircuitOpen))
+	assert.Equal(t, "other", ErrorClass(fmt.Errorf("Something else")))
+}
+
+func TestInstrumented(t *testing.T) {
+	metrics := &recordingMetrics{}
+	in := NewInstrumented(FakeReadWriter{testTagName: 7}, metrics, InstrumentLayer("device"), InstrumentPerTag())
+
+	var actual int
+	require.NoError(t, in.ReadTag(testTagName, &actual))
+	require.NoError(t, in.WriteTag(testTagName, 8))
+	require.Error(t, in.ReadTag("missing", &actual))
+
+	assert.Equal(t, []Observation{
+		{Layer: "device", Op: "ReadTag", TagName: testTagName},
+		{Layer: "device", Op: "WriteTag", TagName: testTagName},
+		{Layer: "device", Op: "ReadTag", TagName: "missing", ErrorClass: "other"},
+	}, metrics.observations)
+}
+
+func TestInstrumentedGauges(t *testing.T) {
+	metrics := &recordingMetrics{}
+	pooled := NewPooled(FakeReadWriter{testTagName: 7}, 2)
+	defer pooled.Close()
+	NewInstrumented(pooled, metrics, InstrumentLayer("pool"))
+
+	refresher := NewRefresher(FakeReadWriter{testTagName: 7}, time.Hour)
+	defer refresher.Close()
+	in := NewInstrumentedReader(refresher, metrics, InstrumentLayer("refresher"))
+
+	var actual int
+	require.NoError(t, in.ReadTag(testTagName, &actual))
+
+	require.Contains(t, metrics.gauges, "pool.pooled_queue_depth")
+	assert.Equal(t, 0.0, metrics.gauges["pool.pooled_queue_depth"]())
+	assert.Equal(t, 2.0, metrics.gauges["pool.pooled_workers"]())
+	assert.Equal(t, 1.0, metrics.gauges["refresher.refresher_tags"]())
+}
+
+func TestInstrumentedGaugesForPooledPointer(t *testing.T) {
+	metrics := &recordingMetrics{}
+	pooled := NewPooled(FakeReadWriter{testTagName: 7}, 3)
+	defer pooled.Close()
+	NewInstrumented(&pooled, metrics, InstrumentLayer("pool"))
+
+	require.Contains(t, metrics.gauges, "pool.pooled_workers")
+	assert.Equal(t, 0.0, metrics.gauges["pool.pooled_queue_depth"]())
+	assert.Equal(t, 3.0, metrics.gauges["pool.pooled_workers"]())
+}
+
+func newStandardMetricsForTesting() *StandardMetrics {
+	sm := NewStandardMetrics()
+	sm.Observe(Observation{Layer: "device", Op: "ReadTag", Duration: 2 * time.Millisecond})
+	sm.Observe(Observation{Layer: "device", Op: "ReadTag", Duration: 20 * time.Second, ErrorClass: "connection"})
+	sm.Observe(Observation{Op: "WriteTag", TagName: "A", Duration: time.Millisecond})
+	sm.RegisterGauge("pool", "pooled_queue_depth", func() float64 { return 3 })
+	return sm
+}
+
+func TestStandardMetricsPrometheus(t *testing.T) {
+	sm := newStandardMetricsForTesting()
+
+	rec := httptest.NewRecorder()
+	sm.PrometheusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		`plc_operations_total{layer="device",op="ReadTag"} 2`,
+		`plc_operations_total{op="WriteTag",tag="A"} 1`,
+		`plc_operation_errors_total{layer="device",op="ReadTag",class="connection"} 1`,
+		`plc_operation_duration_seconds_bucket{layer="device",op="ReadTag",le="0.001"} 0`,
+		`plc_operation_duration_seconds_bucket{layer="device",op="ReadTag",le="0.0025"} 1`,
+		`plc_operation_duration_seconds_bucket{layer="device",op="ReadTag",le="10"} 1`,
+		`plc_operation_duration_seconds_bucket{layer="device",op="ReadTag",le="+Inf"} 2`,
+		`plc_operation_duration_seconds_bucket{op="WriteTag",tag="A",le="0.001"} 1`,
+		`plc_operation_duration_seconds_sum{layer="device",op="ReadTag"} 20.002`,
+		`plc_operation_duration_seconds_count{layer="device",op="ReadTag"} 2`,
+		`# TYPE plc_pooled_queue_depth gauge`,
+		`plc_pooled_queue_depth{layer="pool"} 3`,
+	} {
+		assert.Contains(t, strings.Split(body, "\n"), line)
+	}
+}
+
+// expvarTestRuns makes expvar names unique, since expvar panics if a name is published twice (e.g. with -count=2).
+var expvarTestRuns int
+
+func TestStandardMetricsExpvar(t *testing.T) {
+	expvarTestRuns++
+	name := fmt.Sprintf("%s_%d", t.Name(), expvarTestRuns)
+	sm := NewExpvarMetrics(name)
+	sm.Observe(Observation{Op: "ReadTag", Duration: time.Millisecond})
+
+	var snapshot struct {
+		Operations []struct {
+			Op    string
+			Count int
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &snapshot))
+	require.Len(t, snapshot.Operations, 1)
+	assert.Equal(t, "ReadTag", snapshot.Operations[0].Op)
+	assert.Equal(t, 1, snapshot.Operations[0].Count)
+}
+
+func TestStandardMetricsGaugesDontHoldLock(t *testing.T) {
+	sm := NewStandardMetrics()
+	// A gauge which waits on another layer's Observe, like Pooled.Workers during SetWorkers
+	sm.RegisterGauge("pool", "observing", func() float64 {
+		sm.Observe(Observation{Op: "ReadTag", Duration: time.Millisecond})
+		return 1
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sm.Snapshot()
+		sm.WritePrometheus(&strings.Builder{})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "Reading the gauges deadlocked with Observe")
+	}
+}
@@ -1,6 +1,7 @@
 package libplctag
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"time"
@@ -29,7 +30,12 @@ type Device struct {
 	conf    map[string]string
 }
 
-var _ = plc.ReadWriter(&Device{}) // Compiler makes sure this type is a ReadWriter
+var _ = plc.ReadWriter(&Device{})         // Compiler makes sure this type is a ReadWriter
+var _ = plc.ContextReadWriter(&Device{})  // Compiler makes sure this type is a ContextReadWriter
+var _ = plc.BatchReader(&Device{})        // Compiler makes sure this type is a BatchReader
+var _ = plc.BatchWriter(&Device{})        // Compiler makes sure this type is a BatchWriter
+var _ = plc.RangeReader(&Device{})        // Compiler makes sure this type is a RangeReader
+var _ = plc.ContextRangeReader(&Device{}) // Compiler makes sure this type is a ContextRangeReader
 
 // NewDevice creates a new Device at the provided address with options.
 // It is not thread safe. In a multi-threaded context, callers should ensure the appropriate
@@ -102,9 +108,22 @@ func (dev *Device) Close() error {
 // It is not thread safe. In a multi-threaded context, callers should ensure the appropriate
 // portion of the tag tree is locked.
 func (dev *Device) ReadTag(name string, value interface{}) error {
+	return dev.ReadTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext is the same as ReadTag, but the request to the PLC is abandoned if ctx is done first.
+// A bit of an integer (e.g. "Status.3") is read into a *bool.
+// An array or slice of integers or floats is read with a single request to the PLC, which is much
+// faster than reading each element individually. Its length is the number of elements read.
+// Other arrays and slices, such as of strings, are read one element at a time.
+func (dev *Device) ReadTagContext(ctx context.Context, name string, value interface{}) error {
 	v := reflect.ValueOf(value)
 	if v.Kind() != reflect.Ptr {
-		return plc.ErrNonPointerRead{TagName: name, Kind: v.Kind()}
+		return plc.WrapOpError(plc.ErrNonPointerRead{TagName: name, Kind: v.Kind()}, "Device", "ReadTag", name)
+	}
+
+	if word, bit, ok := splitBit(name); ok {
+		return dev.readBit(ctx, name, word, bit, value)
 	}
 
 	switch v.Elem().Kind() {
@@ -113,9 +132,9 @@ func (dev *Device) ReadTag(name string, value interface{}) error {
 		for str_index := 0; str_index < stringMaxLength; str_index++ {
 			var val byte
 			tagWithIndex := plc.TagWithIndex(name, str_index)
-			err := dev.rawDevice.ReadTag(tagWithIndex, &val)
+			err := plc.NewContextReader(dev.rawDevice).ReadTagContext(ctx, tagWithIndex, &val)
 			if err != nil {
-				return fmt.Errorf("ReadTag '%s' as string: %w", tagWithIndex, err)
+				return plc.WrapOpError(fmt.Errorf("reading character %d: %w", str_index, err), "Device", "ReadTag", name)
 			}
 			if val == 0 {
 				// We found a null, which is the end of the string
@@ -126,27 +145,229 @@ func (dev *Device) ReadTag(name string, value interface{}) error {
 		}
 		result := string(bytes)
 		v.Elem().Set(reflect.ValueOf(result))
+	case reflect.Array, reflect.Slice:
+		if splitsArray(v.Elem()) {
+			for i := 0; i < v.Elem().Len(); i++ {
+				err := dev.ReadTagContext(ctx, plc.TagWithIndex(name, i), v.Elem().Index(i).Addr().Interface())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		err := plc.NewContextReader(dev.rawDevice).ReadTagContext(ctx, name, value)
+		if err != nil {
+			return plc.WrapOpError(err, "Device", "ReadTag", name)
+		}
 	default:
-		err := dev.rawDevice.ReadTag(name, value)
+		err := plc.NewContextReader(dev.rawDevice).ReadTagContext(ctx, name, value)
 		if err != nil {
-			return fmt.Errorf("ReadTag '%s': %w", name, err)
+			return plc.WrapOpError(err, "Device", "ReadTag", name)
 		}
 	}
 
 	return nil
 }
 
+// ReadRange reads consecutive elements of the named array, starting at index start, into value,
+// which must be a pointer to an array or slice.
+// Integers and floats are read with a single request to the PLC, which is much faster than
+// reading each element individually. Other types, such as strings, are read one element at a time.
+// It is not thread safe. In a multi-threaded context, callers should ensure the appropriate
+// portion of the tag tree is locked.
+func (dev *Device) ReadRange(name string, start int, value interface{}) error {
+	return dev.ReadRangeContext(context.Background(), name, start, value)
+}
+
+// ReadRangeContext is the same as ReadRange, but the request to the PLC is abandoned if ctx is done first.
+func (dev *Device) ReadRangeContext(ctx context.Context, name string, start int, value interface{}) error {
+	arr, err := plc.RangeElements(name, value)
+	if err != nil {
+		return plc.WrapOpError(err, "Device", "ReadRange", name)
+	}
+
+	if crr, ok := dev.rawDevice.(plc.ContextRangeReader); ok && isNumericKind(arr.Type().Elem().Kind()) {
+		err := crr.ReadRangeContext(ctx, name, start, value)
+		return plc.WrapOpError(err, "Device", "ReadRange", name)
+	}
+
+	for i := 0; i < arr.Len(); i++ {
+		err := dev.ReadTagContext(ctx, plc.TagWithIndex(name, start+i), arr.Index(i).Addr().Interface())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitsArray returns true if v is an array or slice which the PLC can't transfer in a single
+// request, so each element must be transferred separately.
+func splitsArray(v reflect.Value) bool {
+	return (v.Kind() == reflect.Array || v.Kind() == reflect.Slice) && !isNumericKind(v.Type().Elem().Kind())
+}
+
+// isNumericKind returns true for the integer and float kinds, which have a fixed size in a PLC array.
+// A PLC array of bools is packed into integers, so bools aren't included.
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
 // WriteTag writes the provided tag and value.
 // It is not thread safe. In a multi-threaded context, callers should ensure the appropriate
 // portion of the tag tree is locked.
 func (dev *Device) WriteTag(name string, value interface{}) error {
-	err := dev.rawDevice.WriteTag(name, value)
+	return dev.WriteTagContext(context.Background(), name, value)
+}
+
+// WriteTagContext is the same as WriteTag, but the request to the PLC is abandoned if ctx is done first.
+// An abandoned write may or may not have reached the PLC.
+// A bit of an integer (e.g. "Status.3") is written from a bool. The bit's name is passed to
+// libplctag, which changes only that bit with a single read-modify-write on the controller, so
+// other bits of the integer which change at the same time aren't overwritten.
+// An array or slice of integers or floats is written with a single request to the PLC.
+// Other arrays and slices are written one element at a time.
+func (dev *Device) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	if _, _, ok := splitBit(name); ok {
+		return dev.writeBit(ctx, name, value)
+	}
+
+	if arr := reflect.Indirect(reflect.ValueOf(value)); splitsArray(arr) {
+		for i := 0; i < arr.Len(); i++ {
+			if err := dev.WriteTagContext(ctx, plc.TagWithIndex(name, i), arr.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := plc.NewContextWriter(dev.rawDevice).WriteTagContext(ctx, name, value)
+	return plc.WrapOpError(err, "Device", "WriteTag", name)
+}
+
+// splitBit returns the name of the integer and the bit number if the name ends in a bit segment.
+func splitBit(name string) (string, int, bool) {
+	path, err := plc.ParseTagPath(name)
+	if err != nil || path.Bit() == plc.NoBit {
+		return "", plc.NoBit, false
+	}
+	return path.Word().String(), path.Bit(), true
+}
+
+// bitWord returns the smallest unsigned integer which contains the bit.
+// Since PLC integers are little-endian, this reads or writes the low bytes of a larger integer.
+func bitWord(bit int) reflect.Value {
+	switch {
+	case bit < 8:
+		return reflect.New(reflect.TypeOf(uint8(0))).Elem()
+	case bit < 16:
+		return reflect.New(reflect.TypeOf(uint16(0))).Elem()
+	case bit < 32:
+		return reflect.New(reflect.TypeOf(uint32(0))).Elem()
+	default:
+		return reflect.New(reflect.TypeOf(uint64(0))).Elem()
+	}
+}
+
+func (dev *Device) readBit(ctx context.Context, name, word string, bit int, value interface{}) error {
+	result, ok := value.(*bool)
+	if !ok {
+		return plc.WrapOpError(fmt.Errorf("%w: a bit must be read into a *bool, not %T", plc.ErrBadRequest, value), "Device", "ReadTag", name)
+	}
+
+	wordVal := bitWord(bit)
+	err := plc.NewContextReader(dev.rawDevice).ReadTagContext(ctx, word, wordVal.Addr().Interface())
 	if err != nil {
-		return fmt.Errorf("WriteTag '%s': %w", name, err)
+		return plc.WrapOpError(err, "Device", "ReadTag", name)
 	}
+
+	*result = wordVal.Uint()&(1<<uint(bit)) != 0
 	return nil
 }
 
+// writeBit writes the bit tag itself, rather than reading and writing the integer containing it,
+// so the read-modify-write happens on the controller without clobbering the integer's other bits.
+func (dev *Device) writeBit(ctx context.Context, name string, value interface{}) error {
+	if _, ok := value.(bool); !ok {
+		return plc.WrapOpError(fmt.Errorf("%w: a bit must be written from a bool, not %T", plc.ErrBadRequest, value), "Device", "WriteTag", name)
+	}
+
+	err := plc.NewContextWriter(dev.rawDevice).WriteTagContext(ctx, name, value)
+	return plc.WrapOpError(err, "Device", "WriteTag", name)
+}
+
+// ReadTags reads all of the provided tags.
+// Other than strings, bits, and arrays which are read one element at a time, all requests are sent
+// to the PLC before waiting for any of the responses, which is much faster than reading each tag
+// individually.
+// It is not thread safe. In a multi-threaded context, callers should ensure the appropriate
+// portion of the tag tree is locked.
+func (dev *Device) ReadTags(tags []plc.TagValue) error {
+	var raw []plc.TagValue
+	var rawIndices []int
+	for i, tag := range tags {
+		v := reflect.ValueOf(tag.Value)
+		if _, _, isBit := splitBit(tag.Name); isBit || v.Kind() != reflect.Ptr || v.Elem().Kind() == reflect.String || splitsArray(v.Elem()) {
+			tags[i].Err = dev.ReadTag(tag.Name, tag.Value) // Can't be batched
+			continue
+		}
+		raw = append(raw, plc.TagValue{Name: tag.Name, Value: tag.Value})
+		rawIndices = append(rawIndices, i)
+	}
+
+	if brd, ok := dev.rawDevice.(plc.BatchReader); ok {
+		brd.ReadTags(raw)
+	} else {
+		for i := range raw {
+			raw[i].Err = dev.rawDevice.ReadTag(raw[i].Name, raw[i].Value)
+		}
+	}
+
+	for i, tagIndex := range rawIndices {
+		tags[tagIndex].Err = plc.WrapOpError(raw[i].Err, "Device", "ReadTag", raw[i].Name)
+	}
+	return plc.BatchResult(tags)
+}
+
+// WriteTags writes all of the provided tags.
+// Other than bits and arrays which are written one element at a time, all requests are sent to the
+// PLC before waiting for any of the responses, which is much faster than writing each tag
+// individually. If the same tag is written more than once, the writes are applied in order.
+// Bits and those arrays are written first.
+// It is not thread safe. In a multi-threaded context, callers should ensure the appropriate
+// portion of the tag tree is locked.
+func (dev *Device) WriteTags(tags []plc.TagValue) error {
+	var raw []plc.TagValue
+	var rawIndices []int
+	for i, tag := range tags {
+		if _, _, isBit := splitBit(tag.Name); isBit || splitsArray(reflect.Indirect(reflect.ValueOf(tag.Value))) {
+			tags[i].Err = dev.WriteTag(tag.Name, tag.Value) // Can't be batched
+			continue
+		}
+		raw = append(raw, plc.TagValue{Name: tag.Name, Value: tag.Value})
+		rawIndices = append(rawIndices, i)
+	}
+
+	if bwr, ok := dev.rawDevice.(plc.BatchWriter); ok {
+		bwr.WriteTags(raw)
+	} else {
+		for i := range raw {
+			raw[i].Err = dev.rawDevice.WriteTag(raw[i].Name, raw[i].Value)
+		}
+	}
+
+	for i, tagIndex := range rawIndices {
+		tags[tagIndex].Err = plc.WrapOpError(raw[i].Err, "Device", "WriteTag", raw[i].Name)
+	}
+	return plc.BatchResult(tags)
+}
+
 // GetAllTags gets a list of all tags available on the Device.
 func (dev *Device) GetAllTags() ([]plc.Tag, error) {
 	tags, programs, err := dev.rawDevice.GetList("", "")
@@ -1,6 +1,7 @@
 package libplctag
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
@@ -52,8 +53,13 @@ func TestReadTagRequiresPointer(t *testing.T) {
 		require.Failf(t, "Incorrect error type for non-pointer read", "Received error: %v", err)
 	}
 
-	expectedError := plc.ErrNonPointerRead{TagName: testTagName, Kind: reflect.Int}
-	assert.Equal(t, err, expectedError, "Error should be of correct type")
+	var nonPointerErr plc.ErrNonPointerRead
+	require.True(t, errors.As(err, &nonPointerErr), "Error should be of correct type")
+	assert.Equal(t, plc.ErrNonPointerRead{TagName: testTagName, Kind: reflect.Int}, nonPointerErr)
+
+	var opErr plc.OpError
+	require.True(t, errors.As(err, &opErr), "Error should be an OpError")
+	assert.Equal(t, plc.OpError{Op: "ReadTag", TagName: testTagName, Layer: "Device", Err: nonPointerErr}, opErr)
 }
 
 func TestReadString(t *testing.T) {
@@ -68,6 +74,13 @@ func TestReadString(t *testing.T) {
 	err := dev.ReadTag("STR", &str)
 	assert.NoError(t, err)
 	assert.Equal(t, "hi", str, "String should be loaded from array elements ending in null")
+
+	delete(fake.FakeReadWriter, "STR[2]")
+	err = dev.ReadTag("STR", &str)
+	var opErr plc.OpError
+	require.True(t, errors.As(err, &opErr), "Error should be an OpError")
+	assert.Equal(t, "STR", opErr.TagName, "The error should name the string, not the character")
+	assert.Contains(t, err.Error(), "character 2")
 }
 
 func TestReadTag(t *testing.T) {
@@ -94,6 +107,19 @@ func TestWriteTag(t *testing.T) {
 	assert.Equal(t, 9, fake.FakeReadWriter[testTagName])
 }
 
+func TestReadTagContextCancelled(t *testing.T) {
+	fake := FakeRawDevice{plc.FakeReadWriter{testTagName: int(7)}}
+	dev := newTestDevice(&fake)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var result int
+	err := dev.ReadTagContext(ctx, testTagName, &result)
+	assert.True(t, errors.Is(err, context.Canceled), "Cancelled read should return the context's error")
+	assert.Equal(t, 0, result)
+}
+
 var _ = plc.ReadWriter(FakeRawDevice{}) // Compiler makes sure this type is a ReadWriter
 var _ = rawDevice(FakeRawDevice{})      // Compiler makes sure this type is a rawDevice
 
@@ -109,3 +135,193 @@ func (dev FakeRawDevice) Close() error {
 func (dev FakeRawDevice) GetList(listName, prefix string) ([]plc.Tag, []string, error) {
 	return nil, nil, nil
 }
+
+func TestReadTags(t *testing.T) {
+	fake := FakeRawDevice{plc.FakeReadWriter{
+		testTagName: int(7),
+		"STR[0]":    uint8('h'),
+		"STR[1]":    uint8(0),
+	}}
+	dev := newTestDevice(&fake)
+
+	var result int
+	var str string
+	var missing int
+	tags := []plc.TagValue{
+		{Name: testTagName, Value: &result},
+		{Name: "STR", Value: &str},
+		{Name: "MISSING", Value: &missing},
+	}
+	err := dev.ReadTags(tags)
+	require.Error(t, err)
+
+	assert.NoError(t, tags[0].Err)
+	assert.NoError(t, tags[1].Err)
+	assert.Error(t, tags[2].Err)
+	assert.Equal(t, 7, result)
+	assert.Equal(t, "h", str)
+}
+
+func TestWriteTags(t *testing.T) {
+	fake := FakeRawDevice{plc.FakeReadWriter{}}
+	dev := newTestDevice(&fake)
+
+	err := dev.WriteTags([]plc.TagValue{{Name: "A", Value: 1}, {Name: "B", Value: 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, plc.FakeReadWriter{"A": 1, "B": 2}, fake.FakeReadWriter)
+}
+
+func TestReadBit(t *testing.T) {
+	fake := FakeRawDevice{plc.FakeReadWriter{
+		"STATUS":      uint8(0x08),
+		"WIDE.STATUS": uint32(1 << 20),
+	}}
+	dev := newTestDevice(&fake)
+
+	var bit bool
+	require.NoError(t, dev.ReadTag("STATUS.3", &bit))
+	assert.True(t, bit)
+	require.NoError(t, dev.ReadTag("STATUS.2", &bit))
+	assert.False(t, bit)
+	require.NoError(t, dev.ReadTag("WIDE.STATUS.20", &bit))
+	assert.True(t, bit)
+
+	var notBool int
+	err := dev.ReadTag("STATUS.3", &notBool)
+	assert.True(t, errors.Is(err, plc.ErrBadRequest), "A bit must be read into a bool: %v", err)
+
+	err = dev.ReadTag("MISSING.1", &bit)
+	var opErr plc.OpError
+	require.True(t, errors.As(err, &opErr))
+	assert.Equal(t, "MISSING.1", opErr.TagName, "Errors should name the bit tag, not the integer")
+}
+
+func TestWriteBit(t *testing.T) {
+	fake := FakeRawDevice{plc.FakeReadWriter{"STATUS": uint16(0x0101)}}
+	dev := newTestDevice(&fake)
+
+	// The bit tag is written by libplctag, rather than reading and writing the whole integer
+	require.NoError(t, dev.WriteTag("STATUS.9", true))
+	require.NoError(t, dev.WriteTag("STATUS.8", false))
+	assert.Equal(t, plc.FakeReadWriter{"STATUS": uint16(0x0101), "STATUS.9": true, "STATUS.8": false}, fake.FakeReadWriter)
+
+	err := dev.WriteTag("STATUS.8", 1)
+	assert.True(t, errors.Is(err, plc.ErrBadRequest), "A bit must be written from a bool: %v", err)
+	var opErr plc.OpError
+	require.True(t, errors.As(err, &opErr))
+	assert.Equal(t, "STATUS.8", opErr.TagName)
+
+}
+
+func TestReadWriteBitBatch(t *testing.T) {
+	fake := FakeRawDevice{plc.FakeReadWriter{"STATUS": uint8(0x04), testTagName: int(7)}}
+	dev := newTestDevice(&fake)
+
+	var bit bool
+	var result int
+	require.NoError(t, dev.ReadTags([]plc.TagValue{{Name: "STATUS.2", Value: &bit}, {Name: testTagName, Value: &result}}))
+	assert.True(t, bit)
+	assert.Equal(t, 7, result)
+
+	require.NoError(t, dev.WriteTags([]plc.TagValue{{Name: "STATUS.0", Value: true}, {Name: testTagName, Value: 8}}))
+	assert.Equal(t, plc.FakeReadWriter{"STATUS": uint8(0x04), "STATUS.0": true, testTagName: 8}, fake.FakeReadWriter)
+}
+
+// FakeRangeRawDevice adds range reads to a FakeRawDevice. Each element is read as its index.
+type FakeRangeRawDevice struct {
+	FakeRawDevice
+	calls []string
+}
+
+func (dev *FakeRangeRawDevice) ReadRangeContext(ctx context.Context, name string, start int, value interface{}) error {
+	arr, err := plc.RangeElements(name, value)
+	if err != nil {
+		return err
+	}
+	dev.calls = append(dev.calls, plc.TagWithIndex(name, start))
+	for i := 0; i < arr.Len(); i++ {
+		arr.Index(i).Set(reflect.ValueOf(float32(start + i)))
+	}
+	return nil
+}
+
+func TestReadRange(t *testing.T) {
+	fake := FakeRangeRawDevice{}
+	dev := newTestDevice(&fake)
+
+	actual := make([]float32, 100)
+	require.NoError(t, dev.ReadRange("ARR", 100, &actual))
+	assert.Equal(t, []string{"ARR[100]"}, fake.calls, "The whole range should be read with one request")
+	assert.Equal(t, float32(100), actual[0])
+	assert.Equal(t, float32(199), actual[99])
+}
+
+func TestReadRangeOfStrings(t *testing.T) {
+	fake := FakeRangeRawDevice{FakeRawDevice: FakeRawDevice{plc.FakeReadWriter{
+		"STR[3][0]": uint8('h'),
+		"STR[3][1]": uint8(0),
+		"STR[4][0]": uint8(0),
+	}}}
+	dev := newTestDevice(&fake)
+
+	var actual [2]string
+	require.NoError(t, dev.ReadRange("STR", 3, &actual))
+	assert.Empty(t, fake.calls, "Strings should be read one element at a time")
+	assert.Equal(t, [2]string{"h", ""}, actual)
+}
+
+func TestReadRangeWithoutRangeReader(t *testing.T) {
+	fake := FakeRawDevice{plc.FakeReadWriter{"ARR[1]": int32(5), "ARR[2]": int32(6)}}
+	dev := newTestDevice(&fake)
+
+	var actual [2]int32
+	require.NoError(t, dev.ReadRange("ARR", 1, &actual))
+	assert.Equal(t, [2]int32{5, 6}, actual)
+
+	err := dev.ReadRange("ARR", 1, actual)
+	assert.True(t, errors.Is(err, plc.ErrBadRequest), "Wrong error: %v", err)
+}
+
+func TestReadWholeArray(t *testing.T) {
+	fake := FakeRawDevice{plc.FakeReadWriter{
+		"ARR":       []float32{1.5, 2.5},
+		"ROWS[0]":   [2]int16{1, 2},
+		"ROWS[1]":   [2]int16{3, 4},
+		"STR[0][0]": uint8('h'),
+		"STR[0][1]": uint8(0),
+	}}
+	dev := newTestDevice(&fake)
+
+	actual := make([]float32, 2)
+	require.NoError(t, dev.ReadTag("ARR", &actual), "The array should be read with one request")
+	assert.Equal(t, []float32{1.5, 2.5}, actual)
+
+	var rows [2][2]int16
+	require.NoError(t, dev.ReadTag("ROWS", &rows), "Each row should be read with one request")
+	assert.Equal(t, [2][2]int16{{1, 2}, {3, 4}}, rows)
+
+	var strs [1]string
+	require.NoError(t, dev.ReadTag("STR", &strs), "Strings should be read one element at a time")
+	assert.Equal(t, [1]string{"h"}, strs)
+
+	batchResult := make([]float32, 2)
+	require.NoError(t, dev.ReadTags([]plc.TagValue{{Name: "ARR", Value: &batchResult}}))
+	assert.Equal(t, []float32{1.5, 2.5}, batchResult)
+}
+
+func TestWriteWholeArray(t *testing.T) {
+	fake := FakeRawDevice{plc.FakeReadWriter{}}
+	dev := newTestDevice(&fake)
+
+	require.NoError(t, dev.WriteTag("ARR", []float32{1.5, 2.5}))
+	require.NoError(t, dev.WriteTag("STR", []string{"a", "b"}))
+	assert.Equal(t, plc.FakeReadWriter{
+		"ARR":    []float32{1.5, 2.5},
+		"STR[0]": "a",
+		"STR[1]": "b",
+	}, fake.FakeReadWriter)
+
+	require.NoError(t, dev.WriteTags([]plc.TagValue{{Name: "BATCH", Value: []int32{1}}, {Name: "NAMES", Value: [1]string{"c"}}}))
+	assert.Equal(t, []int32{1}, fake.FakeReadWriter["BATCH"])
+	assert.Equal(t, "c", fake.FakeReadWriter["NAMES[0]"])
+}
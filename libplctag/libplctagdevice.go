@@ -6,8 +6,11 @@ package libplctag
 */
 import "C"
 import (
+	"context"
 	"fmt"
 	"math"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -110,8 +113,13 @@ type device struct {
 	timeout C.int
 }
 
-var _ = rawDevice(&device{})      // Compiler makes sure this type is a rawDevice
-var _ = plc.ReadWriter(&device{}) // Compiler makes sure this type is a ReadWriter
+var _ = rawDevice(&device{})              // Compiler makes sure this type is a rawDevice
+var _ = plc.ReadWriter(&device{})         // Compiler makes sure this type is a ReadWriter
+var _ = plc.ContextReadWriter(&device{})  // Compiler makes sure this type is a ContextReadWriter
+var _ = plc.BatchReader(&device{})        // Compiler makes sure this type is a BatchReader
+var _ = plc.BatchWriter(&device{})        // Compiler makes sure this type is a BatchWriter
+var _ = plc.RangeReader(&device{})        // Compiler makes sure this type is a RangeReader
+var _ = plc.ContextRangeReader(&device{}) // Compiler makes sure this type is a ContextRangeReader
 
 // newLibplctagDevice creates a new libplctagDevice.
 // The conConf string provides IP and other connection configuration (see libplctag for options).
@@ -134,156 +142,483 @@ func (dev *device) Close() error {
 }
 
 const (
+	pollInterval     = time.Millisecond // How often to check the status of a pending non-blocking operation
 	noOffset         = C.int(0)
 	stringDataOffset = 4
 	stringMaxLength  = 82 // Size according to libplctag. Seems like an underlying protocol thing.
 )
 
-func (dev *device) getID(tagName string) (C.int32_t, error) {
-	val, ok := dev.ids.Load(tagName)
+// getID returns the ID of the libplctag tag for tagName, creating it if necessary.
+// If elemCount is more than 1, the tag covers that many consecutive array elements starting at
+// tagName, so it's cached separately from the tag for the single element.
+func (dev *device) getID(ctx context.Context, tagName string, elemCount int) (C.int32_t, error) {
+	attribs := "&name=" + tagName
+	if elemCount > 1 {
+		attribs += "&elem_count=" + strconv.Itoa(elemCount)
+	}
+
+	val, ok := dev.ids.Load(attribs)
 	if ok {
 		return val.(C.int32_t), nil
 	}
 
-	cattrib_str := C.CString(dev.conConf + "&name=" + tagName) // can also specify elem_size=1&elem_count=1
+	cattrib_str := C.CString(dev.conConf + attribs)
 	defer C.free(unsafe.Pointer(cattrib_str))
 
-	id := C.plc_tag_create(cattrib_str, dev.timeout)
+	if ctx.Done() == nil {
+		// The context can't be cancelled, so just block.
+		id := C.plc_tag_create(cattrib_str, dev.timeout)
+		if id < 0 {
+			return id, errorFromLibplctagReturnCode(id)
+		}
+		dev.ids.Store(attribs, id)
+		return id, nil
+	}
+
+	id := C.plc_tag_create(cattrib_str, 0)
 	if id < 0 {
 		return id, errorFromLibplctagReturnCode(id)
 	}
-	dev.ids.Store(tagName, id)
+	if err := dev.await(ctx, id, C.plc_tag_status(id)); err != nil {
+		C.plc_tag_destroy(id)
+		return id, err
+	}
+	dev.ids.Store(attribs, id)
 	return id, nil
 }
 
+// read reads the tag's data from the PLC into libplctag's buffer.
+func (dev *device) read(ctx context.Context, id C.int32_t) error {
+	if ctx.Done() == nil {
+		return errorFromLibplctagReturnCode(C.plc_tag_read(id, dev.timeout))
+	}
+	return dev.await(ctx, id, C.plc_tag_read(id, 0))
+}
+
+// write writes libplctag's buffer for the tag to the PLC.
+func (dev *device) write(ctx context.Context, id C.int32_t) error {
+	if ctx.Done() == nil {
+		return errorFromLibplctagReturnCode(C.plc_tag_write(id, dev.timeout))
+	}
+	return dev.await(ctx, id, C.plc_tag_write(id, 0))
+}
+
+// await polls a non-blocking libplctag operation until it is no longer pending.
+// If ctx is done or the device timeout expires first, the operation is aborted.
+func (dev *device) await(ctx context.Context, id C.int32_t, status C.int32_t) error {
+	timeout := time.NewTimer(time.Duration(dev.timeout) * time.Millisecond)
+	defer timeout.Stop()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+
+	for status == C.PLCTAG_STATUS_PENDING {
+		select {
+		case <-ctx.Done():
+			C.plc_tag_abort(id)
+			return ctx.Err()
+		case <-timeout.C:
+			C.plc_tag_abort(id)
+			return errorFromLibplctagReturnCode(C.PLCTAG_ERR_TIMEOUT)
+		case <-poll.C:
+			status = C.plc_tag_status(id)
+		}
+	}
+	return errorFromLibplctagReturnCode(status)
+}
+
 // ReadTag reads the requested tag into the provided value.
 // It is not thread safe. In a multi-threaded context, callers should ensure the appropriate
 // portion of the tag tree is locked.
 func (dev *device) ReadTag(name string, value interface{}) error {
-	id, err := dev.getID(name)
+	return dev.ReadTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext is the same as ReadTag, but if ctx is done before the PLC responds,
+// the request is aborted.
+// If value points to an array or slice, that many elements are read with a single request.
+func (dev *device) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	count := elemCount(value)
+	if count == 0 {
+		return nil
+	}
+
+	id, err := dev.getID(ctx, name, count)
 	if err != nil {
 		return fmt.Errorf("ReadTag: %w", err)
 	}
 
-	if err := errorFromLibplctagReturnCode(C.plc_tag_read(id, dev.timeout)); err != nil {
+	if err := dev.read(ctx, id); err != nil {
 		return fmt.Errorf("ReadTag: %w", err)
 	}
 
+	if err := getTagValue(id, value); err != nil {
+		return fmt.Errorf("ReadTag: %w", err)
+	}
+
+	return nil
+}
+
+// ReadRange reads consecutive elements of the named array, starting at index start, into value,
+// which must be a pointer to an array or slice of a supported type.
+// It is not thread safe. In a multi-threaded context, callers should ensure the appropriate
+// portion of the tag tree is locked.
+func (dev *device) ReadRange(name string, start int, value interface{}) error {
+	return dev.ReadRangeContext(context.Background(), name, start, value)
+}
+
+// ReadRangeContext is the same as ReadRange, but if ctx is done before the PLC responds,
+// the request is aborted.
+// All of the elements are read with a single request, in the same way as an array is read by ReadTag.
+func (dev *device) ReadRangeContext(ctx context.Context, name string, start int, value interface{}) error {
+	if _, err := plc.RangeElements(name, value); err != nil {
+		return fmt.Errorf("ReadRange: %w", err)
+	}
+	return dev.ReadTagContext(ctx, plc.TagWithIndex(name, start), value)
+}
+
+// WriteTag writes the provided tag and value.
+// It is not thread safe. In a multi-threaded context, callers should ensure the appropriate
+// portion of the tag tree is locked.
+func (dev *device) WriteTag(name string, value interface{}) error {
+	return dev.WriteTagContext(context.Background(), name, value)
+}
+
+// WriteTagContext is the same as WriteTag, but if ctx is done before the PLC responds,
+// the request is aborted. An aborted write may or may not have reached the PLC.
+// If value is an array or slice, or points to one, all of its elements are written with a single request.
+// If the name is a bit tag (e.g. "Status.3"), value must be a bool. libplctag writes a bit tag
+// with a read-modify-write request, so the controller changes only that bit.
+func (dev *device) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	count := elemCount(value)
+	if count == 0 {
+		return nil
+	}
+
+	id, err := dev.getID(ctx, name, count)
+	if err != nil {
+		return fmt.Errorf("WriteTag: %w", err)
+	}
+
+	if isBitTag(name) {
+		err = setBit(id, value)
+	} else {
+		err = setTagValue(id, value)
+	}
+	if err != nil {
+		return fmt.Errorf("WriteTag: %w", err)
+	}
+
+	// Read. If non-zero, value is true. Otherwise, it's false.
+	if err := dev.write(ctx, id); err != nil {
+		return fmt.Errorf("WriteTag: %w", err)
+	}
+
+	return nil
+}
+
+// ReadTags reads all of the tags using non-blocking requests, so the PLC can respond to
+// all of them together instead of waiting for each round trip.
+func (dev *device) ReadTags(tags []plc.TagValue) error {
+	for _, round := range uniqueRounds(tags) {
+		ids := dev.startBatch(tags, round, nil, func(id C.int32_t) C.int32_t {
+			return C.plc_tag_read(id, 0)
+		})
+		for i, tagIndex := range round {
+			if tags[tagIndex].Err == nil {
+				tags[tagIndex].Err = getTagValue(ids[i], tags[tagIndex].Value)
+			}
+		}
+	}
+	return plc.BatchResult(tags)
+}
+
+// WriteTags writes all of the tags using non-blocking requests, so the PLC can respond to
+// all of them together instead of waiting for each round trip.
+func (dev *device) WriteTags(tags []plc.TagValue) error {
+	for _, round := range uniqueRounds(tags) {
+		setTag := func(id C.int32_t, tag plc.TagValue) error {
+			return setTagValue(id, tag.Value)
+		}
+		dev.startBatch(tags, round, setTag, func(id C.int32_t) C.int32_t {
+			return C.plc_tag_write(id, 0)
+		})
+	}
+	return plc.BatchResult(tags)
+}
+
+// uniqueRounds splits the indices of tags into rounds in which no tag name is repeated.
+// libplctag only allows one operation at a time on a tag, so duplicates must wait for the next round.
+func uniqueRounds(tags []plc.TagValue) [][]int {
+	var rounds [][]int
+	roundOfName := map[string]int{}
+	for i, tag := range tags {
+		round := roundOfName[tag.Name]
+		roundOfName[tag.Name] = round + 1
+		if round == len(rounds) {
+			rounds = append(rounds, nil)
+		}
+		rounds[round] = append(rounds[round], i)
+	}
+	return rounds
+}
+
+// startBatch starts a non-blocking operation (as created by start) for each tag in the round,
+// then waits until all of them have completed or the device timeout expires.
+// If prepare is not nil, it is called for each tag before the operation starts.
+// The result for each tag is stored in its Err field, and the tag IDs are returned.
+func (dev *device) startBatch(tags []plc.TagValue, round []int, prepare func(C.int32_t, plc.TagValue) error, start func(C.int32_t) C.int32_t) []C.int32_t {
+	ids := make([]C.int32_t, len(round))
+	pending := map[int]bool{}
+	for i, tagIndex := range round {
+		tag := &tags[tagIndex]
+		var err error
+		count := elemCount(tag.Value)
+		if count == 0 {
+			continue // There's nothing to transfer
+		}
+		ids[i], err = dev.getID(context.Background(), tag.Name, count)
+		if err != nil {
+			tag.Err = err
+			continue
+		}
+		if prepare != nil {
+			if err := prepare(ids[i], *tag); err != nil {
+				tag.Err = err
+				continue
+			}
+		}
+
+		status := start(ids[i])
+		if status == C.PLCTAG_STATUS_PENDING {
+			pending[i] = true
+		} else {
+			tag.Err = errorFromLibplctagReturnCode(status)
+		}
+	}
+
+	timeout := time.NewTimer(time.Duration(dev.timeout) * time.Millisecond)
+	defer timeout.Stop()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+
+	for len(pending) > 0 {
+		select {
+		case <-timeout.C:
+			for i := range pending {
+				C.plc_tag_abort(ids[i])
+				tags[round[i]].Err = errorFromLibplctagReturnCode(C.PLCTAG_ERR_TIMEOUT)
+			}
+			return ids
+		case <-poll.C:
+		}
+
+		for i := range pending {
+			status := C.plc_tag_status(ids[i])
+			if status != C.PLCTAG_STATUS_PENDING {
+				delete(pending, i)
+				tags[round[i]].Err = errorFromLibplctagReturnCode(status)
+			}
+		}
+	}
+	return ids
+}
+
+// isBitTag returns true if the name ends in a bit segment, which libplctag treats as a bit tag.
+func isBitTag(name string) bool {
+	path, err := plc.ParseTagPath(name)
+	return err == nil && path.Bit() != plc.NoBit
+}
+
+// setBit sets libplctag's buffer for a bit tag. For a bit tag, libplctag uses the bit from the
+// tag's name rather than the offset.
+func setBit(id C.int32_t, value interface{}) error {
+	set, ok := value.(bool)
+	if !ok {
+		return fmt.Errorf("%w: a bit must be written from a bool, not %T", plc.ErrBadRequest, value)
+	}
+	val := C.int(0)
+	if set {
+		val = 1
+	}
+	return errorFromLibplctagReturnCode(C.plc_tag_set_bit(id, 0, val))
+}
+
+// elements returns the array or slice which value is or points to. It returns false if value
+// isn't an array or slice.
+func elements(value interface{}) (reflect.Value, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Array && v.Kind() != reflect.Slice {
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+// elemCount returns the number of elements to transfer for value: the length of an array or
+// slice, or 1 for anything else.
+func elemCount(value interface{}) int {
+	if arr, ok := elements(value); ok {
+		return arr.Len()
+	}
+	return 1
+}
+
+// getTagValue decodes libplctag's buffer for the tag into value, which must be a pointer to a
+// supported type, or to an array or slice of one. Each element is decoded at its offset.
+func getTagValue(id C.int32_t, value interface{}) error {
+	arr, ok := elements(value)
+	if !ok {
+		return getValue(id, noOffset, value)
+	}
+	if arr.Kind() == reflect.Array && !arr.CanAddr() {
+		return fmt.Errorf("%w: an array must be read into a pointer, not %T", plc.ErrBadRequest, value)
+	}
+	if arr.Len() == 0 {
+		return nil
+	}
+
+	elemSize := C.plc_tag_get_size(id) / C.int(arr.Len())
+	for i := 0; i < arr.Len(); i++ {
+		if err := getValue(id, C.int(i)*elemSize, arr.Index(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTagValue encodes value into libplctag's buffer for the tag. If value is an array or slice,
+// or points to one, each element is encoded at its offset.
+func setTagValue(id C.int32_t, value interface{}) error {
+	arr, ok := elements(value)
+	if !ok {
+		return setValue(id, noOffset, value)
+	}
+	if arr.Len() == 0 {
+		return nil
+	}
+
+	elemSize := C.plc_tag_get_size(id) / C.int(arr.Len())
+	for i := 0; i < arr.Len(); i++ {
+		if err := setValue(id, C.int(i)*elemSize, arr.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getValue decodes the data at offset in libplctag's buffer for the tag into value, which must be a pointer.
+func getValue(id C.int32_t, offset C.int, value interface{}) error {
 	switch val := value.(type) {
 	case *bool:
-		result, err := getUint8(id, noOffset)
+		result, err := getUint8(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = uint8(result) > 0
 	case *uint8:
-		result, err := getUint8(id, noOffset)
+		result, err := getUint8(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = uint8(result)
 	case *uint16:
-		result, err := getUint16(id, noOffset)
+		result, err := getUint16(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = uint16(result)
 	case *uint32:
-		result, err := getUint32(id, noOffset)
+		result, err := getUint32(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = uint32(result)
 	case *uint64:
-		result, err := getUint64(id, noOffset)
+		result, err := getUint64(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = uint64(result)
 	case *int8:
-		result, err := getInt8(id, noOffset)
+		result, err := getInt8(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = int8(result)
 	case *int16:
-		result, err := getInt16(id, noOffset)
+		result, err := getInt16(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = int16(result)
 	case *int32:
-		result, err := getInt32(id, noOffset)
+		result, err := getInt32(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = int32(result)
 	case *int64:
-		result, err := getInt64(id, noOffset)
+		result, err := getInt64(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = int64(result)
 	case *float32:
-		result, err := getFloat32(id, noOffset)
+		result, err := getFloat32(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = float32(result)
 	case *float64:
-		result, err := getFloat64(id, noOffset)
+		result, err := getFloat64(id, offset)
 		if err != nil {
-			return fmt.Errorf("ReadTag: %w", err)
+			return err
 		}
 		*val = float64(result)
 	default:
-		return fmt.Errorf("ReadTag: %w: unknown type %T (%v)", plc.ErrBadRequest, val, val)
+		return fmt.Errorf("%w: unknown type %T (%v)", plc.ErrBadRequest, val, val)
 	}
 
 	return nil
 }
 
-// WriteTag writes the provided tag and value.
-// It is not thread safe. In a multi-threaded context, callers should ensure the appropriate
-// portion of the tag tree is locked.
-func (dev *device) WriteTag(name string, value interface{}) error {
-	id, err := dev.getID(name)
-	if err != nil {
-		return fmt.Errorf("WriteTag: %w", err)
-	}
-
+// setValue encodes value into libplctag's buffer for the tag at offset.
+func setValue(id C.int32_t, offset C.int, value interface{}) error {
+	var err error
 	switch val := value.(type) {
 	case bool:
 		b := C.uint8_t(0)
 		if val {
 			b = C.uint8_t(255)
 		}
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_uint8(id, noOffset, b))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_uint8(id, offset, b))
 	case uint8:
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_uint8(id, noOffset, C.uint8_t(val)))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_uint8(id, offset, C.uint8_t(val)))
 	case uint16:
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_uint16(id, noOffset, C.uint16_t(val)))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_uint16(id, offset, C.uint16_t(val)))
 	case uint32:
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_uint32(id, noOffset, C.uint32_t(val)))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_uint32(id, offset, C.uint32_t(val)))
 	case uint64:
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_uint64(id, noOffset, C.uint64_t(val)))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_uint64(id, offset, C.uint64_t(val)))
 	case int8:
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_int8(id, noOffset, C.int8_t(val)))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_int8(id, offset, C.int8_t(val)))
 	case int16:
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_int16(id, noOffset, C.int16_t(val)))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_int16(id, offset, C.int16_t(val)))
 	case int32:
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_int32(id, noOffset, C.int32_t(val)))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_int32(id, offset, C.int32_t(val)))
 	case int64:
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_int64(id, noOffset, C.int64_t(val)))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_int64(id, offset, C.int64_t(val)))
 	case float32:
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_float32(id, noOffset, C.float(val)))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_float32(id, offset, C.float(val)))
 	case float64:
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_float64(id, noOffset, C.double(val)))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_float64(id, offset, C.double(val)))
 	case string:
 		// write the string length
-		err = errorFromLibplctagReturnCode(C.plc_tag_set_int32(id, noOffset, C.int32_t(len(val))))
+		err = errorFromLibplctagReturnCode(C.plc_tag_set_int32(id, offset, C.int32_t(len(val))))
 		if err != nil {
-			return fmt.Errorf("WriteTag: %w", err)
+			return err
 		}
 
 		// copy the data
@@ -293,24 +628,15 @@ func (dev *device) WriteTag(name string, value interface{}) error {
 				byt = val[str_index]
 			}
 
-			err = errorFromLibplctagReturnCode(C.plc_tag_set_uint8(id, C.int(stringDataOffset+str_index), C.uint8_t(byt)))
+			err = errorFromLibplctagReturnCode(C.plc_tag_set_uint8(id, offset+C.int(stringDataOffset+str_index), C.uint8_t(byt)))
 			if err != nil {
-				return fmt.Errorf("WriteTag: %w", err)
+				return err
 			}
 		}
 	default:
 		err = fmt.Errorf("Type %T is unknown and can't be written (%v)", val, val)
 	}
-	if err != nil {
-		return fmt.Errorf("WriteTag: %w", err)
-	}
-
-	// Read. If non-zero, value is true. Otherwise, it's false.
-	if err := errorFromLibplctagReturnCode(C.plc_tag_write(id, dev.timeout)); err != nil {
-		return fmt.Errorf("WriteTag: %w", err)
-	}
-
-	return nil
+	return err
 }
 
 func (dev *device) GetList(listName, prefix string) ([]plc.Tag, []string, error) {
@@ -320,7 +646,7 @@ func (dev *device) GetList(listName, prefix string) ([]plc.Tag, []string, error)
 		listName += ".@tags"
 	}
 
-	id, err := dev.getID(listName)
+	id, err := dev.getID(context.Background(), listName, 1)
 	if err != nil {
 		return nil, nil, fmt.Errorf("GetList: %w", err)
 	}
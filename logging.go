@@ -0,0 +1,213 @@
+package plc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// LogLevel is the severity of a LogRecord.
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota // Successful reads
+	LogInfo                  // Successful writes
+	LogWarn                  // Operations abandoned because the context was done
+	LogError                 // Failed operations
+)
+
+func (lvl LogLevel) String() string {
+	switch lvl {
+	case LogDebug:
+		return "DEBUG"
+	case LogInfo:
+		return "INFO"
+	case LogWarn:
+		return "WARN"
+	case LogError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LogLevel(%d)", int(lvl))
+	}
+}
+
+// LogRecord describes a single operation which passed through a Logging.
+type LogRecord struct {
+	Level    LogLevel
+	Name     string // The name of the Logging which produced the record, if one was set with LogName
+	Op       string // "ReadTag" or "WriteTag"
+	TagName  string
+	Value    interface{} // The value which was read or written (not a pointer); nil if it failed
+	Duration time.Duration
+	Err      error
+}
+
+// Logger is the interface that wraps the Log method, which is called for each LogRecord.
+// It may be called concurrently.
+type Logger interface {
+	Log(LogRecord)
+}
+
+// LoggerFunc is a func which satisfies the Logger interface.
+type LoggerFunc func(LogRecord)
+
+func (f LoggerFunc) Log(rec LogRecord) { f(rec) }
+
+// NewStdLogger returns a Logger which prints each record with the standard library log package.
+// If lg is nil, the standard logger is used.
+func NewStdLogger(lg *log.Logger) Logger {
+	return LoggerFunc(func(rec LogRecord) {
+		msg := formatLogRecord(rec)
+		if lg == nil {
+			log.Print(msg)
+		} else {
+			lg.Print(msg)
+		}
+	})
+}
+
+func formatLogRecord(rec LogRecord) string {
+	prefix := rec.Level.String()
+	if rec.Name != "" {
+		prefix += " " + rec.Name
+	}
+	if rec.Err != nil {
+		return fmt.Sprintf("%s: %s '%s' failed after %v: %v", prefix, rec.Op, rec.TagName, rec.Duration, rec.Err)
+	}
+	return fmt.Sprintf("%s: %s '%s' is %v (%v)", prefix, rec.Op, rec.TagName, rec.Value, rec.Duration)
+}
+
+// Logging wraps a Reader and Writer and sends a LogRecord to a Logger for every operation.
+// See LogLevel for the level used for each type of record.
+type Logging struct {
+	reader   Reader
+	writer   Writer
+	logger   Logger
+	name     string
+	minLevel LogLevel
+	sample   uint64
+	count    *uint64 // The number of successful operations, for sampling
+}
+
+var _ = ReadWriter(Logging{})        // Compiler makes sure this type is a ReadWriter
+var _ = ContextReadWriter(Logging{}) // Compiler makes sure this type is a ContextReadWriter
+
+// LoggingOption configures a Logging.
+type LoggingOption interface {
+	apply(*Logging)
+}
+
+// loggingOptionFunc wraps a func so it satisfies the LoggingOption interface.
+type loggingOptionFunc func(*Logging)
+
+func (f loggingOptionFunc) apply(lg *Logging) { f(lg) }
+
+// LogName sets the Name of every record, which is useful if several Loggings share a Logger.
+func LogName(name string) LoggingOption {
+	return loggingOptionFunc(func(lg *Logging) {
+		lg.name = name
+	})
+}
+
+// LogMinLevel causes records below level to be dropped. By default, all records are logged.
+func LogMinLevel(level LogLevel) LoggingOption {
+	return loggingOptionFunc(func(lg *Logging) {
+		lg.minLevel = level
+	})
+}
+
+// LogSample causes only one of every n successful operations which pass LogMinLevel to be logged, which is useful
+// for frequent polling. Failures are always logged.
+func LogSample(n int) LoggingOption {
+	return loggingOptionFunc(func(lg *Logging) {
+		if n > 0 {
+			lg.sample = uint64(n)
+		}
+	})
+}
+
+// NewLogging returns a Logging which logs all operations on rw.
+func NewLogging(rw ReadWriter, logger Logger, opts ...LoggingOption) Logging {
+	return newLogging(rw, rw, logger, opts)
+}
+
+// NewLoggingReader returns a Logging which logs reads from rd. It is for Readers which
+// aren't Writers, such as a CacheReader. Writes to it fail with ErrBadRequest.
+func NewLoggingReader(rd Reader, logger Logger, opts ...LoggingOption) Logging {
+	return newLogging(rd, nil, logger, opts)
+}
+
+func newLogging(rd Reader, wr Writer, logger Logger, opts []LoggingOption) Logging {
+	lg := Logging{
+		reader: rd,
+		writer: wr,
+		logger: logger,
+		sample: 1,
+		count:  new(uint64),
+	}
+	for _, opt := range opts {
+		opt.apply(&lg)
+	}
+	return lg
+}
+
+func (lg Logging) ReadTag(name string, value interface{}) error {
+	return lg.ReadTagContext(context.Background(), name, value)
+}
+
+func (lg Logging) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	start := time.Now()
+	err := NewContextReader(lg.reader).ReadTagContext(ctx, name, value)
+	lg.log("ReadTag", name, value, time.Since(start), err)
+	return err
+}
+
+func (lg Logging) WriteTag(name string, value interface{}) error {
+	return lg.WriteTagContext(context.Background(), name, value)
+}
+
+func (lg Logging) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	if lg.writer == nil {
+		return WrapOpError(fmt.Errorf("%w: Logging has no Writer", ErrBadRequest), "Logging", "WriteTag", name)
+	}
+	start := time.Now()
+	err := NewContextWriter(lg.writer).WriteTagContext(ctx, name, value)
+	lg.log("WriteTag", name, value, time.Since(start), err)
+	return err
+}
+
+func (lg Logging) log(op, name string, value interface{}, duration time.Duration, err error) {
+	rec := LogRecord{
+		Level:    LogError,
+		Name:     lg.name,
+		Op:       op,
+		TagName:  name,
+		Duration: duration,
+		Err:      err,
+	}
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		rec.Level = LogWarn
+	case err != nil:
+		// Keep LogError
+	case op == "WriteTag":
+		rec.Level = LogInfo
+	default:
+		rec.Level = LogDebug
+	}
+	if rec.Level < lg.minLevel {
+		return
+	}
+	if err == nil && atomic.AddUint64(lg.count, 1)%lg.sample != 1%lg.sample {
+		return // Not sampled; only operations which pass the level are counted
+	}
+
+	if v := reflect.Indirect(reflect.ValueOf(value)); err == nil && v.IsValid() {
+		rec.Value = v.Interface() // After a failed read, the value is just whatever it held before
+	}
+	lg.logger.Log(rec)
+}
@@ -0,0 +1,108 @@
+package plc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLogger stores every record it receives.
+type recordingLogger struct {
+	mutex   sync.Mutex
+	records []LogRecord
+}
+
+func (rl *recordingLogger) Log(rec LogRecord) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rec.Duration = 0 // So records can be compared
+	rl.records = append(rl.records, rec)
+}
+
+func TestLoggingRecords(t *testing.T) {
+	logger := &recordingLogger{}
+	lg := NewLogging(FakeReadWriter{testTagName: 7}, logger, LogName("test"))
+
+	var actual int
+	require.NoError(t, lg.ReadTag(testTagName, &actual))
+	require.NoError(t, lg.WriteTag(testTagName, 8))
+	require.Error(t, lg.ReadTag("missing", &actual))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, lg.ReadTagContext(ctx, testTagName, &actual))
+
+	require.Len(t, logger.records, 4)
+	assert.Equal(t, LogRecord{Level: LogDebug, Name: "test", Op: "ReadTag", TagName: testTagName, Value: 7}, logger.records[0])
+	assert.Equal(t, LogRecord{Level: LogInfo, Name: "test", Op: "WriteTag", TagName: testTagName, Value: 8}, logger.records[1])
+	assert.Equal(t, LogError, logger.records[2].Level)
+	assert.Equal(t, "missing", logger.records[2].TagName)
+	assert.Error(t, logger.records[2].Err)
+	assert.Nil(t, logger.records[2].Value, "A failed read has no value")
+	assert.Equal(t, LogWarn, logger.records[3].Level)
+	assert.Equal(t, context.Canceled, logger.records[3].Err)
+}
+
+func TestLoggingMinLevel(t *testing.T) {
+	logger := &recordingLogger{}
+	lg := NewLogging(FakeReadWriter{testTagName: 7}, logger, LogMinLevel(LogInfo))
+
+	var actual int
+	require.NoError(t, lg.ReadTag(testTagName, &actual))
+	require.NoError(t, lg.WriteTag(testTagName, 8))
+
+	require.Len(t, logger.records, 1)
+	assert.Equal(t, "WriteTag", logger.records[0].Op)
+}
+
+func TestLoggingSample(t *testing.T) {
+	logger := &recordingLogger{}
+	lg := NewLogging(FakeReadWriter{testTagName: 7}, logger, LogSample(3))
+
+	var actual int
+	for i := 0; i < 7; i++ {
+		require.NoError(t, lg.ReadTag(testTagName, &actual))
+	}
+	require.Error(t, lg.ReadTag("missing", &actual))
+
+	assert.Len(t, logger.records, 4, "Reads 1, 4, and 7 should be logged, and the failure is always logged")
+}
+
+func TestLoggingSampleAfterMinLevel(t *testing.T) {
+	logger := &recordingLogger{}
+	lg := NewLogging(FakeReadWriter{testTagName: 7}, logger, LogMinLevel(LogInfo), LogSample(2))
+
+	var actual int
+	for i := 0; i < 4; i++ {
+		require.NoError(t, lg.ReadTag(testTagName, &actual)) // Dropped by level, so not counted
+		require.NoError(t, lg.WriteTag(testTagName, i))
+	}
+
+	require.Len(t, logger.records, 2, "Writes 0 and 2 should be logged")
+	assert.Equal(t, 0, logger.records[0].Value)
+	assert.Equal(t, 2, logger.records[1].Value)
+}
+
+func TestLoggingReader(t *testing.T) {
+	logger := &recordingLogger{}
+	lg := NewLoggingReader(FakeReadWriter{testTagName: 7}, logger)
+
+	err := lg.WriteTag(testTagName, 8)
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	assert.Len(t, logger.records, 0)
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0))
+
+	logger.Log(LogRecord{Level: LogDebug, Name: "READ", Op: "ReadTag", TagName: testTagName, Value: 7})
+	logger.Log(LogRecord{Level: LogError, Op: "WriteTag", TagName: testTagName, Err: ErrPlcConnection})
+	assert.Equal(t, "DEBUG READ: ReadTag 'TEST_TAG' is 7 (0s)\nERROR: WriteTag 'TEST_TAG' failed after 0s: PLC connection error\n", buf.String())
+}
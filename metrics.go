@@ -0,0 +1,268 @@
+package plc
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency histogram buckets.
+var DefaultLatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// StandardMetrics is a Metrics which keeps counts and latency histograms in memory.
+// They can be published with expvar, or served in the Prometheus text exposition format.
+type StandardMetrics struct {
+	mutex   sync.Mutex
+	buckets []float64
+	series  map[seriesKey]*series
+	gauges  map[gaugeKey]func() float64
+}
+
+var _ = Metrics(&StandardMetrics{}) // Compiler makes sure this type is a Metrics
+
+type seriesKey struct {
+	layer, op, tagName string
+}
+
+type gaugeKey struct {
+	layer, name string
+}
+
+// series holds the measurements for one combination of layer, operation, and tag.
+type series struct {
+	count   int64
+	errors  map[string]int64 // Counts by ErrorClass
+	buckets []int64          // Non-cumulative counts for each of the StandardMetrics buckets
+	sum     float64          // Total latency in seconds
+}
+
+// NewStandardMetrics returns an empty StandardMetrics which uses DefaultLatencyBuckets.
+func NewStandardMetrics() *StandardMetrics {
+	return &StandardMetrics{
+		buckets: DefaultLatencyBuckets,
+		series:  map[seriesKey]*series{},
+		gauges:  map[gaugeKey]func() float64{},
+	}
+}
+
+// NewExpvarMetrics returns a StandardMetrics which is published with expvar under the provided name.
+// Like expvar.Publish, it panics if the name is already in use.
+func NewExpvarMetrics(name string) *StandardMetrics {
+	sm := NewStandardMetrics()
+	expvar.Publish(name, expvar.Func(sm.Snapshot))
+	return sm
+}
+
+func (sm *StandardMetrics) Observe(obs Observation) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	key := seriesKey{obs.Layer, obs.Op, obs.TagName}
+	s, ok := sm.series[key]
+	if !ok {
+		s = &series{
+			errors:  map[string]int64{},
+			buckets: make([]int64, len(sm.buckets)),
+		}
+		sm.series[key] = s
+	}
+
+	seconds := obs.Duration.Seconds()
+	s.count++
+	s.sum += seconds
+	if obs.ErrorClass != "" {
+		s.errors[obs.ErrorClass]++
+	}
+	if i := sort.SearchFloat64s(sm.buckets, seconds); i < len(sm.buckets) {
+		s.buckets[i]++
+	} // else it only counts toward +Inf
+}
+
+func (sm *StandardMetrics) RegisterGauge(layer, name string, value func() float64) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.gauges[gaugeKey{layer, name}] = value
+}
+
+// Snapshot returns the current metrics in a form which can be marshalled to JSON.
+// It is used for expvar.
+func (sm *StandardMetrics) Snapshot() interface{} {
+	sm.mutex.Lock()
+	ops := []map[string]interface{}{}
+	for _, key := range sm.sortedSeriesKeys() {
+		s := sm.series[key]
+		buckets := map[string]int64{}
+		var cumulative int64
+		for i, bound := range sm.buckets {
+			cumulative += s.buckets[i]
+			buckets[formatFloat(bound)] = cumulative
+		}
+		errs := map[string]int64{}
+		for class, count := range s.errors {
+			errs[class] = count
+		}
+		ops = append(ops, map[string]interface{}{
+			"layer":       key.layer,
+			"op":          key.op,
+			"tag":         key.tagName,
+			"count":       s.count,
+			"errors":      errs,
+			"sum_seconds": s.sum,
+			"buckets":     buckets,
+		})
+	}
+
+	gaugeFuncs := sm.copyGauges()
+	sm.mutex.Unlock()
+
+	// The gauges are read without holding the mutex, since they may take locks of their own
+	gauges := map[string]float64{}
+	for key, value := range gaugeFuncs {
+		name := key.name
+		if key.layer != "" {
+			name = key.layer + "." + name
+		}
+		gauges[name] = value()
+	}
+
+	return map[string]interface{}{
+		"operations": ops,
+		"gauges":     gauges,
+	}
+}
+
+// PrometheusHandler returns an http.Handler which serves the metrics in the Prometheus
+// text exposition format.
+func (sm *StandardMetrics) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		sm.WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format.
+func (sm *StandardMetrics) WritePrometheus(w io.Writer) error {
+	sm.mutex.Lock()
+	var sb strings.Builder
+	keys := sm.sortedSeriesKeys()
+
+	sb.WriteString("# HELP plc_operations_total Number of PLC operations.\n")
+	sb.WriteString("# TYPE plc_operations_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "plc_operations_total%s %d\n", key.labels(), sm.series[key].count)
+	}
+
+	sb.WriteString("# HELP plc_operation_errors_total Number of failed PLC operations by error class.\n")
+	sb.WriteString("# TYPE plc_operation_errors_total counter\n")
+	for _, key := range keys {
+		errs := sm.series[key].errors
+		classes := make([]string, 0, len(errs))
+		for class := range errs {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(&sb, "plc_operation_errors_total%s %d\n", key.labels("class", class), errs[class])
+		}
+	}
+
+	sb.WriteString("# HELP plc_operation_duration_seconds Latency of PLC operations.\n")
+	sb.WriteString("# TYPE plc_operation_duration_seconds histogram\n")
+	for _, key := range keys {
+		s := sm.series[key]
+		var cumulative int64
+		for i, bound := range sm.buckets {
+			cumulative += s.buckets[i]
+			fmt.Fprintf(&sb, "plc_operation_duration_seconds_bucket%s %d\n", key.labels("le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(&sb, "plc_operation_duration_seconds_bucket%s %d\n", key.labels("le", "+Inf"), s.count)
+		fmt.Fprintf(&sb, "plc_operation_duration_seconds_sum%s %s\n", key.labels(), formatFloat(s.sum))
+		fmt.Fprintf(&sb, "plc_operation_duration_seconds_count%s %d\n", key.labels(), s.count)
+	}
+
+	gauges := sm.copyGauges()
+	sm.mutex.Unlock()
+
+	// The gauges are read without holding the mutex, since they may take locks of their own
+	gaugeKeys := make([]gaugeKey, 0, len(gauges))
+	for key := range gauges {
+		gaugeKeys = append(gaugeKeys, key)
+	}
+	sort.Slice(gaugeKeys, func(i, j int) bool {
+		if gaugeKeys[i].name != gaugeKeys[j].name {
+			return gaugeKeys[i].name < gaugeKeys[j].name
+		}
+		return gaugeKeys[i].layer < gaugeKeys[j].layer
+	})
+	for i, key := range gaugeKeys {
+		if i == 0 || gaugeKeys[i-1].name != key.name {
+			fmt.Fprintf(&sb, "# TYPE plc_%s gauge\n", key.name)
+		}
+		labels := ""
+		if key.layer != "" {
+			labels = formatLabels("layer", key.layer)
+		}
+		fmt.Fprintf(&sb, "plc_%s%s %s\n", key.name, labels, formatFloat(gauges[key]()))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// copyGauges returns a copy of the registered gauges. The mutex must be held.
+func (sm *StandardMetrics) copyGauges() map[gaugeKey]func() float64 {
+	gauges := make(map[gaugeKey]func() float64, len(sm.gauges))
+	for key, value := range sm.gauges {
+		gauges[key] = value
+	}
+	return gauges
+}
+
+func (sm *StandardMetrics) sortedSeriesKeys() []seriesKey {
+	keys := make([]seriesKey, 0, len(sm.series))
+	for key := range sm.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.layer != b.layer {
+			return a.layer < b.layer
+		}
+		if a.op != b.op {
+			return a.op < b.op
+		}
+		return a.tagName < b.tagName
+	})
+	return keys
+}
+
+// labels formats the key's labels, followed by any extra name/value pairs.
+func (key seriesKey) labels(extra ...string) string {
+	pairs := []string{}
+	if key.layer != "" {
+		pairs = append(pairs, "layer", key.layer)
+	}
+	pairs = append(pairs, "op", key.op)
+	if key.tagName != "" {
+		pairs = append(pairs, "tag", key.tagName)
+	}
+	return formatLabels(append(pairs, extra...)...)
+}
+
+// formatLabels formats name/value pairs as Prometheus labels.
+func formatLabels(pairs ...string) string {
+	labels := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, pairs[i]+"="+strconv.Quote(pairs[i+1]))
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -1,47 +1,560 @@
 package plc
 
+import (
+	"context"
+	"sync"
+)
+
 // Pooled wraps another plc.ReadWriter with a work pool that runs a set number of concurrent operations.
+//
+// Operations on the same tag name are always run in the order they were submitted, even if they
+// are handled by different workers. Operations on different tags may run in any order, and by
+// default workers pick randomly between waiting reads and writes. PoolWritePriority and
+// PoolWeighted can be used to give writes precedence.
 type Pooled struct {
 	plc         ReadWriter
 	read, write tasker
+	ctl         *poolControl
 }
 
-var _ = ReadWriter(Pooled{}) // Compiler makes sure this type is a ReadWriter
+var _ = ReadWriter(Pooled{})         // Compiler makes sure this type is a ReadWriter
+var _ = ContextReadWriter(Pooled{})  // Compiler makes sure this type is a ContextReadWriter
+var _ = BatchReader(Pooled{})        // Compiler makes sure this type is a BatchReader
+var _ = BatchWriter(Pooled{})        // Compiler makes sure this type is a BatchWriter
+var _ = Closer(Pooled{})             // Compiler makes sure this type is a Closer
+var _ = ContextRangeReader(Pooled{}) // Compiler makes sure this type is a ContextRangeReader
+
+// poolControl is the state shared by all copies of a Pooled.
+type poolControl struct {
+	mutex          sync.Mutex
+	workers        int
+	queueSize      int
+	rejectWhenFull bool
+	writeWeight    int // Along with readWeight, how often workers prefer writes over reads
+	readWeight     int
+	order          tagOrder
+	shrink         chan struct{}  // Each value received stops one worker
+	quit           chan struct{}  // Closed when the Pooled is closed
+	done           chan struct{}  // Closed once all workers have stopped
+	wg             sync.WaitGroup // Tracks running workers
+}
+
+// PooledOption configures a Pooled.
+type PooledOption interface {
+	apply(*poolControl)
+}
+
+// pooledOptionFunc wraps a func so it satisfies the PooledOption interface.
+type pooledOptionFunc func(*poolControl)
+
+func (f pooledOptionFunc) apply(ctl *poolControl) { f(ctl) }
+
+// PoolQueueSize sets how many reads and how many writes can wait in the queue for a worker.
+// By default the queue has no capacity, so each operation waits until a worker is available.
+// At most size operations can wait for an earlier operation on the same tag; more wait for room,
+// just like operations waiting for a full queue, and get it in the order they were submitted. With PoolRejectWhenFull, operations waiting for
+// an earlier operation on the same tag also count against the queue size.
+func PoolQueueSize(size int) PooledOption {
+	return pooledOptionFunc(func(ctl *poolControl) {
+		ctl.queueSize = size
+	})
+}
+
+// PoolRejectWhenFull causes operations to immediately fail with ErrQueueFull if the queue
+// is full, instead of waiting for space. It is usually combined with PoolQueueSize.
+func PoolRejectWhenFull() PooledOption {
+	return pooledOptionFunc(func(ctl *poolControl) {
+		ctl.rejectWhenFull = true
+	})
+}
+
+// PoolWritePriority causes workers to always take a waiting write before any waiting read,
+// so writes are never stuck behind a flood of reads. Note that reads could be starved.
+func PoolWritePriority() PooledOption {
+	return PoolWeighted(1, 0)
+}
+
+// PoolWeighted causes workers to prefer writes for `writes` operations, then prefer reads for
+// `reads` operations, and so on. For example, PoolWeighted(3, 1) takes up to 3 writes for every
+// read when both are waiting. If the preferred type isn't waiting, the other is taken instead.
+func PoolWeighted(writes, reads int) PooledOption {
+	return pooledOptionFunc(func(ctl *poolControl) {
+		ctl.writeWeight, ctl.readWeight = writes, reads
+	})
+}
 
 // NewPooled creates a new Pooled and launches worker goroutines to handle incoming reads and writes.
-// There is no way to kill the workers once they're launched.
-func NewPooled(plc ReadWriter, workers int) Pooled {
-	read, write := make(tasker), make(tasker)
-	for i := 0; i < workers; i++ {
-		go worker(read, write)
+// Close should be called to stop the workers.
+func NewPooled(plc ReadWriter, workers int, opts ...PooledOption) Pooled {
+	ctl := &poolControl{
+		shrink: make(chan struct{}),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.apply(ctl)
+	}
+
+	p := Pooled{
+		plc:   plc,
+		read:  make(tasker, ctl.queueSize),
+		write: make(tasker, ctl.queueSize),
+		ctl:   ctl,
 	}
-	return Pooled{plc, read, write}
+	ctl.order.waiting = map[string][]orderedTask{}
+	ctl.order.blocked = map[string][]blockedTask{}
+	ctl.order.numQueued = map[tasker]int{}
+	p.SetWorkers(workers)
+	return p
+}
+
+// SetWorkers changes the number of workers. If there are too many, idle workers are stopped,
+// so SetWorkers waits until enough workers finish their current operations. Workers reports
+// the new number as soon as SetWorkers is called.
+// It returns ErrClosed if the Pooled has been closed.
+func (p Pooled) SetWorkers(workers int) error {
+	p.ctl.mutex.Lock()
+	select {
+	case <-p.ctl.quit:
+		p.ctl.mutex.Unlock()
+		return ErrClosed
+	default:
+	}
+
+	for ; p.ctl.workers < workers; p.ctl.workers++ {
+		p.ctl.wg.Add(1)
+		go p.worker()
+	}
+	stop := 0
+	for ; p.ctl.workers > workers && p.ctl.workers > 0; p.ctl.workers-- {
+		stop++
+	}
+	p.ctl.mutex.Unlock()
+
+	// Waiting for workers to become idle must not hold the mutex, or Workers and Close would block too.
+	for ; stop > 0; stop-- {
+		select {
+		case p.ctl.shrink <- struct{}{}:
+		case <-p.ctl.quit:
+			return nil // Close stops all of the workers anyway
+		}
+	}
+	return nil
+}
+
+// Workers returns the current number of workers.
+func (p Pooled) Workers() int {
+	p.ctl.mutex.Lock()
+	defer p.ctl.mutex.Unlock()
+	return p.ctl.workers
+}
+
+// QueueDepth returns the number of operations waiting for a worker, including operations
+// waiting for an earlier operation on the same tag.
+func (p Pooled) QueueDepth() int {
+	return len(p.read) + len(p.write) + p.ctl.order.numWaiting()
+}
+
+// Close stops accepting new operations, waits for the workers to finish all queued
+// operations, and then stops the workers. Operations submitted after Close return ErrClosed.
+// Close always returns nil, and it is safe to call more than once.
+func (p Pooled) Close() error {
+	p.ctl.mutex.Lock()
+	select {
+	case <-p.ctl.quit:
+		p.ctl.mutex.Unlock()
+		<-p.ctl.done // Already closed, but make sure the workers are finished
+		return nil
+	default:
+	}
+	close(p.ctl.quit)
+	p.ctl.workers = 0
+	p.ctl.mutex.Unlock()
+
+	p.ctl.wg.Wait()
+	close(p.ctl.done)
+	return nil
 }
 
 func (p Pooled) ReadTag(name string, value interface{}) error {
-	return p.read.task(func() error { return p.plc.ReadTag(name, value) })
+	return p.ReadTagContext(context.Background(), name, value)
 }
 
 func (p Pooled) WriteTag(name string, value interface{}) error {
-	return p.write.task(func() error { return p.plc.WriteTag(name, value) })
+	return p.WriteTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext queues the read for the next available worker.
+// If ctx is done while the read is still queued, ReadTagContext returns immediately, but the read
+// stays in the queue (counting toward QueueDepth and the queue size) until a worker takes it and
+// skips it. If ctx is done while a worker is reading, the context is passed to the underlying
+// ReadWriter to abandon the read.
+func (p Pooled) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	err := p.task(ctx, p.read, name, func() error { return NewContextReader(p.plc).ReadTagContext(ctx, name, value) })
+	return WrapOpError(err, "Pooled", "ReadTag", name)
+}
+
+// WriteTagContext queues the write for the next available worker.
+// Cancellation behaves the same as ReadTagContext.
+func (p Pooled) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	err := p.task(ctx, p.write, name, func() error { return NewContextWriter(p.plc).WriteTagContext(ctx, name, value) })
+	return WrapOpError(err, "Pooled", "WriteTag", name)
+}
+
+func (p Pooled) ReadRange(name string, start int, value interface{}) error {
+	return p.ReadRangeContext(context.Background(), name, start, value)
+}
+
+// ReadRangeContext queues a read of the whole range for the next available worker if the
+// underlying ReadWriter can read it with one call. Otherwise each element is queued separately,
+// so the reads are spread across all of the workers. See RangeReader for details.
+func (p Pooled) ReadRangeContext(ctx context.Context, name string, start int, value interface{}) error {
+	arr, err := RangeElements(name, value)
+	if err != nil {
+		return WrapOpError(err, "Pooled", "ReadRange", name)
+	}
+	if !readsRangeAtOnce(p.plc, arr) {
+		return NewSplitReaderParallel(p, SplitConcurrency(p.Workers())).readRangeElements(ctx, name, start, arr)
+	}
+	err = p.task(ctx, p.read, name, func() error { return ReadRangeContext(ctx, p.plc, name, start, value) })
+	return WrapOpError(err, "Pooled", "ReadRange", name)
+}
+
+// ReadTags queues every read and then waits for all of them, so the reads are
+// spread across all of the workers.
+func (p Pooled) ReadTags(tags []TagValue) error {
+	return p.batch(p.read, "ReadTag", tags, p.plc.ReadTag)
+}
+
+// WriteTags queues every write and then waits for all of them, so the writes are
+// spread across all of the workers. Writes to the same tag are applied in the order they
+// appear in tags, but the order of writes to different tags is not defined.
+func (p Pooled) WriteTags(tags []TagValue) error {
+	return p.batch(p.write, "WriteTag", tags, p.plc.WriteTag)
+}
+
+// task is an operation on a tag which a worker should run.
+type task struct {
+	name string
+	run  func()
+	fail func(error) // Reports the error if the task can't be queued
 }
 
-type task func()
 type tasker chan task
 
-func (t tasker) task(f func() error) error {
-	ch := make(chan error)
-	t <- func() { ch <- f() }
-	return <-ch
+// orderedTask is a task which is waiting for an earlier operation on the same tag.
+type orderedTask struct {
+	task
+	queue tasker // The queue the task would have been sent to
+}
+
+// blockedTask is a task which is waiting for room to wait for an earlier operation on the same tag.
+type blockedTask struct {
+	orderedTask
+	added chan struct{} // Closed once the task has been added to the tag's waiting tasks
+}
+
+// tagOrder tracks which tags have an operation queued or running, so later operations on the
+// same tag can wait until it's done.
+type tagOrder struct {
+	mutex     sync.Mutex
+	waiting   map[string][]orderedTask // If a tag is present, it is busy, and these tasks are waiting
+	blocked   map[string][]blockedTask // Tasks which will be added to waiting in this order as it has room
+	numQueued map[tasker]int           // The number of waiting tasks for each queue
+}
+
+// start returns true if the task can be queued now. Otherwise it returns false, and the task
+// will be returned by finish after all earlier operations on the tag.
+// If limit isn't negative and the task would have to wait while its queue already holds that
+// many tasks (including waiting ones), ErrQueueFull is returned instead.
+// If the tag already has maxWaiting tasks waiting, the task is blocked behind any other blocked
+// tasks until there's room, and a channel is returned which is closed once it has been added.
+// To give up before then, call unblock.
+func (to *tagOrder) start(t task, queue tasker, limit, maxWaiting int) (bool, <-chan struct{}, error) {
+	to.mutex.Lock()
+	defer to.mutex.Unlock()
+
+	waiting, busy := to.waiting[t.name]
+	if !busy {
+		to.waiting[t.name] = waiting // Add an empty entry to mark the tag as busy
+		return true, nil, nil
+	}
+	if limit >= 0 && len(queue)+to.numQueued[queue] >= limit {
+		return false, nil, ErrQueueFull
+	}
+	if blocked := to.blocked[t.name]; len(waiting) >= maxWaiting || len(blocked) > 0 {
+		added := make(chan struct{})
+		to.blocked[t.name] = append(blocked, blockedTask{orderedTask{t, queue}, added})
+		return false, added, nil
+	}
+	to.waiting[t.name] = append(waiting, orderedTask{t, queue})
+	to.numQueued[queue]++
+	return false, nil, nil
+}
+
+// unblock removes a task which start blocked. It returns false if the task has already been added
+// to the tag's waiting tasks, in which case it will still be returned by finish.
+func (to *tagOrder) unblock(name string, added <-chan struct{}) bool {
+	to.mutex.Lock()
+	defer to.mutex.Unlock()
+
+	blocked := to.blocked[name]
+	for i := range blocked {
+		if blocked[i].added == added {
+			to.blocked[name] = append(blocked[:i:i], blocked[i+1:]...)
+			if len(to.blocked[name]) == 0 {
+				delete(to.blocked, name)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// numWaiting returns the number of tasks waiting for an earlier operation on the same tag.
+func (to *tagOrder) numWaiting() int {
+	to.mutex.Lock()
+	defer to.mutex.Unlock()
+
+	num := 0
+	for _, waiting := range to.waiting {
+		num += len(waiting)
+	}
+	return num
+}
+
+// queued returns the number of tasks for the queue which are waiting for an earlier operation
+// on the same tag.
+func (to *tagOrder) queued(queue tasker) int {
+	to.mutex.Lock()
+	defer to.mutex.Unlock()
+	return to.numQueued[queue]
+}
+
+// finish is called when an operation on the tag is done (or failed to be queued).
+// If another operation on the tag is waiting, it is returned.
+func (to *tagOrder) finish(name string) (orderedTask, bool) {
+	to.mutex.Lock()
+	defer to.mutex.Unlock()
+
+	waiting := to.waiting[name]
+	if blocked := to.blocked[name]; len(blocked) > 0 {
+		// The first blocked task takes the room left by the task which is finishing
+		waiting = append(waiting, blocked[0].orderedTask)
+		to.numQueued[blocked[0].queue]++
+		close(blocked[0].added)
+		if len(blocked) == 1 {
+			delete(to.blocked, name)
+		} else {
+			to.blocked[name] = blocked[1:]
+		}
+	}
+	if len(waiting) == 0 {
+		delete(to.waiting, name)
+		return orderedTask{}, false
+	}
+	to.waiting[name] = waiting[1:]
+	to.numQueued[waiting[0].queue]--
+	return waiting[0], true
+}
+
+func (p Pooled) task(ctx context.Context, t tasker, name string, f func() error) error {
+	ch, err := p.submit(ctx, t, name, f)
+	if err != nil {
+		return err
+	}
+	return p.wait(ctx, ch)
+}
+
+func (p Pooled) batch(t tasker, op string, tags []TagValue, act action) error {
+	results := make([]<-chan error, len(tags))
+	for i := range tags {
+		tag := tags[i]
+		results[i], tags[i].Err = p.submit(context.Background(), t, tag.Name, func() error { return act(tag.Name, tag.Value) })
+	}
+	for i := range tags {
+		if results[i] != nil {
+			tags[i].Err = p.wait(context.Background(), results[i])
+		}
+		tags[i].Err = WrapOpError(tags[i].Err, "Pooled", op, tags[i].Name)
+	}
+	return BatchResult(tags)
+}
+
+// submit queues f for a worker and returns a channel which will receive its result.
+// If an operation on the same tag is already queued or running, f waits for it instead of
+// joining the queue, and it is run by the same worker once the earlier operation is done.
+func (p Pooled) submit(ctx context.Context, t tasker, name string, f func() error) (<-chan error, error) {
+	ch := make(chan error, 1) // buffered so an abandoned task doesn't block its worker
+	run := func() {
+		if err := ctx.Err(); err != nil {
+			ch <- err // The caller has already given up, so don't bother running it
+			return
+		}
+		ch <- f()
+	}
+	fail := func(err error) { ch <- err }
+
+	select {
+	case <-p.ctl.quit:
+		return nil, ErrClosed
+	default:
+	}
+
+	limit := -1
+	if p.ctl.rejectWhenFull {
+		limit = p.ctl.queueSize
+	}
+	tsk := task{name, run, fail}
+	ready, added, err := p.ctl.order.start(tsk, t, limit, p.ctl.queueSize)
+	if err != nil {
+		return nil, err
+	}
+	if !ready {
+		if added != nil {
+			// Too many operations are already waiting on this tag, so wait like a full queue
+			select {
+			case <-added:
+			case <-p.ctl.quit:
+				if p.ctl.order.unblock(name, added) {
+					return nil, ErrClosed
+				}
+			case <-ctx.Done():
+				if p.ctl.order.unblock(name, added) {
+					return nil, ctx.Err()
+				}
+			}
+		}
+		return ch, nil // It will be run after the earlier operations on this tag
+	}
+
+	err = p.enqueue(ctx, t, tsk)
+	if err != nil {
+		// This task is not going to run, so let the next one on the same tag take its place.
+		go p.enqueueNext(name)
+		return nil, err
+	}
+	return ch, nil
+}
+
+// enqueueNext queues the next task waiting on the tag after the task ahead of it failed to be
+// queued. If that task can't be queued either, it fails with the error, and so on until the tag
+// is no longer busy.
+func (p Pooled) enqueueNext(name string) {
+	for {
+		next, ok := p.ctl.order.finish(name)
+		if !ok {
+			return
+		}
+		err := p.enqueue(context.Background(), next.queue, next.task)
+		if err == nil {
+			return
+		}
+		next.fail(err)
+	}
+}
+
+// enqueue sends the task to a queue, respecting the configuration for a full queue.
+func (p Pooled) enqueue(ctx context.Context, t tasker, tsk task) error {
+	if p.ctl.rejectWhenFull {
+		if p.ctl.queueSize > 0 && len(t)+p.ctl.order.queued(t) >= p.ctl.queueSize {
+			return ErrQueueFull // Operations waiting on the same tag take up the rest of the queue
+		}
+		select {
+		case t <- tsk:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+
+	select {
+	case t <- tsk:
+		return nil
+	case <-p.ctl.quit:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wait waits for the result of a submitted task.
+func (p Pooled) wait(ctx context.Context, ch <-chan error) error {
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.ctl.done:
+		// The workers have all stopped, so the task has either run or never will.
+		select {
+		case err := <-ch:
+			return err
+		default:
+			return ErrClosed
+		}
+	}
+}
+
+// runTask runs the task, followed by any operations on the same tag that were waiting for it.
+func (p Pooled) runTask(t task) {
+	for {
+		t.run()
+		next, ok := p.ctl.order.finish(t.name)
+		if !ok {
+			return
+		}
+		t = next.task
+	}
+}
+
+func (p Pooled) worker() {
+	defer p.ctl.wg.Done()
+
+	weightTotal := p.ctl.writeWeight + p.ctl.readWeight
+	for slot := 0; ; slot++ {
+		// If configured, first check the preferred queue without blocking.
+		var preferred tasker
+		switch {
+		case weightTotal == 0:
+		case slot%weightTotal < p.ctl.writeWeight:
+			preferred = p.write
+		default:
+			preferred = p.read
+		}
+		if preferred != nil {
+			select {
+			case t := <-preferred:
+				p.runTask(t)
+				continue
+			default:
+			}
+		}
+
+		select {
+		case t := <-p.write:
+			p.runTask(t)
+		case t := <-p.read:
+			p.runTask(t)
+		case <-p.ctl.shrink:
+			return
+		case <-p.ctl.quit:
+			p.drain()
+			return
+		}
+	}
 }
 
-func worker(read, write <-chan task) {
+// drain runs tasks until both queues are empty.
+func (p Pooled) drain() {
 	for {
 		select {
-		case t := <-write:
-			t()
-		case t := <-read:
-			t()
+		case t := <-p.write:
+			p.runTask(t)
+		case t := <-p.read:
+			p.runTask(t)
+		default:
+			return
 		}
 	}
 }
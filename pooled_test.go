@@ -1,12 +1,446 @@
 package plc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// blockingReadWriter blocks every operation until release is closed.
+// Each operation's name is sent on started when it begins. For writes, "=value" is appended.
+type blockingReadWriter struct {
+	started chan string
+	release chan struct{}
+}
+
+func newBlockingReadWriter() *blockingReadWriter {
+	return &blockingReadWriter{
+		started: make(chan string, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (brw *blockingReadWriter) ReadTag(name string, value interface{}) error {
+	brw.started <- name
+	<-brw.release
+	return nil
+}
+
+func (brw *blockingReadWriter) WriteTag(name string, value interface{}) error {
+	return brw.ReadTag(fmt.Sprintf("%s=%v", name, value), value)
+}
+
+// waitForStart waits for an operation to start and returns its name.
+func (brw *blockingReadWriter) waitForStart(t *testing.T) string {
+	select {
+	case name := <-brw.started:
+		return name
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timeout waiting for an operation to start")
+		return ""
+	}
+}
+
+// goRead reads in a new goroutine, and returns a channel which receives the result.
+func goRead(rd Reader, name string) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		var unused int
+		result <- rd.ReadTag(name, &unused)
+	}()
+	return result
+}
+
+// goWrite writes in a new goroutine, and returns a channel which receives the result.
+func goWrite(wr Writer, name string, value interface{}) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- wr.WriteTag(name, value)
+	}()
+	return result
+}
+
+func receiveError(t *testing.T, result <-chan error) error {
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timeout waiting for a result")
+		return nil
+	}
+}
+
+func TestPooledClose(t *testing.T) {
+	p := NewPooled(FakeReadWriter{testTagName: 7}, 2)
+
+	var actual int
+	require.NoError(t, p.ReadTag(testTagName, &actual))
+
+	assert.NoError(t, p.Close())
+	assert.NoError(t, p.Close(), "Second Close should be safe")
+	assert.Equal(t, 0, p.Workers())
+
+	assert.Equal(t, OpError{Op: "ReadTag", TagName: testTagName, Layer: "Pooled", Err: ErrClosed}, p.ReadTag(testTagName, &actual))
+	assert.Equal(t, OpError{Op: "WriteTag", TagName: testTagName, Layer: "Pooled", Err: ErrClosed}, p.WriteTag(testTagName, 8))
+	assert.Equal(t, ErrClosed, p.SetWorkers(3))
+}
+
+func TestPooledCloseDrainsQueue(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 1, PoolQueueSize(5))
+
+	first := goRead(p, "first")
+	brw.waitForStart(t)
+
+	// These are queued behind the blocked worker
+	var queued []<-chan error
+	for i := 0; i < 3; i++ {
+		queued = append(queued, goRead(p, fmt.Sprintf("queued%d", i)))
+	}
+	for len(p.read) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error)
+	go func() { closed <- p.Close() }()
+
+	close(brw.release)
+	assert.NoError(t, receiveError(t, first))
+	for _, result := range queued {
+		assert.NoError(t, receiveError(t, result), "Queued reads should complete before Close returns")
+	}
+	assert.NoError(t, receiveError(t, closed))
+}
+
+func TestPooledRejectWhenFull(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 1, PoolQueueSize(1), PoolRejectWhenFull())
+	defer p.Close()
+	defer close(brw.release)
+
+	goRead(p, "running")
+	brw.waitForStart(t)
+	goRead(p, "queued")
+	for len(p.read) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var unused int
+	err := p.ReadTag("rejected", &unused)
+	assert.True(t, errors.Is(err, ErrQueueFull), "Read should be rejected when the queue is full")
+}
+
+func TestPooledSetWorkers(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 1)
+	defer p.Close()
+
+	require.NoError(t, p.SetWorkers(3))
+	assert.Equal(t, 3, p.Workers())
+
+	var results []<-chan error
+	for i := 0; i < 3; i++ {
+		results = append(results, goRead(p, fmt.Sprintf("tag%d", i)))
+	}
+	for i := 0; i < 3; i++ {
+		brw.waitForStart(t) // All 3 must run in parallel
+	}
+	close(brw.release)
+	for _, result := range results {
+		assert.NoError(t, receiveError(t, result))
+	}
+
+	require.NoError(t, p.SetWorkers(1))
+	assert.Equal(t, 1, p.Workers())
+	assert.NoError(t, receiveError(t, goRead(p, "afterShrink")))
+}
+
+func TestPooledShrinkWhileBusy(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 2)
+
+	running := []<-chan error{goRead(p, "tag0"), goRead(p, "tag1")}
+	brw.waitForStart(t)
+	brw.waitForStart(t)
+
+	// Both workers are busy, so SetWorkers waits, but Workers and Close shouldn't
+	shrunk := make(chan error, 1)
+	go func() { shrunk <- p.SetWorkers(1) }()
+	for p.Workers() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- p.Close() }()
+	close(brw.release)
+	for _, result := range running {
+		assert.NoError(t, receiveError(t, result))
+	}
+	assert.NoError(t, receiveError(t, shrunk))
+	assert.NoError(t, receiveError(t, closed))
+}
+
+// numWaiting returns the number of operations waiting for an earlier operation on the tag.
+func (p Pooled) numWaiting(name string) int {
+	p.ctl.order.mutex.Lock()
+	defer p.ctl.order.mutex.Unlock()
+	return len(p.ctl.order.waiting[name])
+}
+
+// numBlocked returns the number of operations waiting for room to wait on the tag.
+func (p Pooled) numBlocked(name string) int {
+	p.ctl.order.mutex.Lock()
+	defer p.ctl.order.mutex.Unlock()
+	return len(p.ctl.order.blocked[name])
+}
+
+func TestPooledWritePriority(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 1, PoolQueueSize(10), PoolWritePriority())
+	defer p.Close()
+
+	results := []<-chan error{goRead(p, "running")}
+	brw.waitForStart(t)
+
+	for i := 0; i < 3; i++ {
+		results = append(results, goRead(p, fmt.Sprintf("read%d", i)))
+	}
+	for len(p.read) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	results = append(results, goWrite(p, "interlock", 1))
+	for len(p.write) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(brw.release)
+	for _, result := range results {
+		assert.NoError(t, receiveError(t, result))
+	}
+	assert.Equal(t, "interlock=1", brw.waitForStart(t), "The write should skip ahead of the queued reads")
+}
+
+func TestPooledWeighted(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 1, PoolQueueSize(10), PoolWeighted(2, 1))
+	defer p.Close()
+
+	results := []<-chan error{goRead(p, "running")}
+	brw.waitForStart(t)
+
+	for i := 0; i < 2; i++ {
+		results = append(results, goRead(p, fmt.Sprintf("read%d", i)))
+		for len(p.read) < i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		results = append(results, goWrite(p, fmt.Sprintf("write%d", i), i))
+		for len(p.write) < i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	close(brw.release)
+	for _, result := range results {
+		assert.NoError(t, receiveError(t, result))
+	}
+	var order []string
+	for i := 0; i < 6; i++ {
+		order = append(order, brw.waitForStart(t))
+	}
+	// The first slot was used by "running", so the worker prefers a write, then a read, then 2 writes...
+	assert.Equal(t, []string{"write0=0", "read0", "write1=1", "write2=2", "read1", "write3=3"}, order)
+}
+
+func TestPooledSameTagOrder(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 2, PoolQueueSize(10))
+	defer p.Close()
+
+	results := []<-chan error{goWrite(p, testTagName, 1)}
+	brw.waitForStart(t)
+
+	// Later writes to the same tag must wait, even though another worker is free
+	for i := 2; i <= 4; i++ {
+		results = append(results, goWrite(p, testTagName, i))
+		for p.numWaiting(testTagName) < i-1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// But a different tag can still use the free worker
+	results = append(results, goRead(p, "other"))
+	assert.Equal(t, "other", brw.waitForStart(t))
+
+	close(brw.release)
+	for _, result := range results {
+		assert.NoError(t, receiveError(t, result))
+	}
+	for i := 2; i <= 4; i++ {
+		assert.Equal(t, fmt.Sprintf("%s=%d", testTagName, i), brw.waitForStart(t))
+	}
+	assert.Equal(t, 0, p.numWaiting(testTagName))
+}
+
+func TestPooledSameTagOrderAfterRejection(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 1, PoolQueueSize(1), PoolRejectWhenFull())
+	defer p.Close()
+
+	results := []<-chan error{goRead(p, "running")}
+	brw.waitForStart(t)
+	results = append(results, goRead(p, "queued"))
+	for len(p.read) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// With a full queue, the first operation on the tag is rejected...
+	var unused int
+	assert.True(t, errors.Is(p.ReadTag(testTagName, &unused), ErrQueueFull))
+
+	close(brw.release)
+	for _, result := range results {
+		assert.NoError(t, receiveError(t, result))
+	}
+
+	// ...and that must not leave the tag stuck as busy
+	assert.NoError(t, receiveError(t, goRead(p, testTagName)))
+	assert.Equal(t, 0, p.numWaiting(testTagName))
+}
+
+func TestPooledSameTagWaiterWhenRejected(t *testing.T) {
+	p := NewPooled(FakeReadWriter{testTagName: 7}, 0, PoolQueueSize(2), PoolRejectWhenFull())
+	defer p.Close()
+
+	// Repeat the steps of a submit whose enqueue fails while another operation on the tag is waiting
+	ahead := task{name: testTagName, run: func() {}, fail: func(error) {}}
+	ready, _, err := p.ctl.order.start(ahead, p.read, 2, 2)
+	require.NoError(t, err)
+	require.True(t, ready)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	waiter, err := p.submit(ctx, p.read, testTagName, func() error { return nil })
+	require.NoError(t, err)
+	require.Equal(t, 1, p.numWaiting(testTagName))
+
+	filled := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		p.read <- task{name: fmt.Sprintf("filler%d", i), run: func() { filled <- struct{}{} }}
+	}
+	require.Equal(t, ErrQueueFull, p.enqueue(context.Background(), p.read, ahead))
+	p.enqueueNext(testTagName)
+
+	assert.Equal(t, ErrQueueFull, receiveError(t, waiter), "The waiter should fail instead of being dropped")
+	assert.Equal(t, 0, p.numWaiting(testTagName))
+
+	require.NoError(t, p.SetWorkers(1))
+	for i := 0; i < 2; i++ {
+		<-filled
+	}
+	assert.NoError(t, receiveError(t, goRead(p, testTagName)), "The tag must not be left busy")
+}
+
+func TestPooledSameTagFloodRejectWhenFull(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 1, PoolQueueSize(2), PoolRejectWhenFull())
+	defer p.Close()
+
+	results := []<-chan error{goRead(p, testTagName)}
+	brw.waitForStart(t)
+	for i := 0; i < 2; i++ {
+		results = append(results, goRead(p, testTagName))
+		for p.numWaiting(testTagName) < i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// Operations waiting on the same tag fill the queue, so more are rejected
+	var unused int
+	for i := 0; i < 10; i++ {
+		err := p.ReadTag(testTagName, &unused)
+		assert.True(t, errors.Is(err, ErrQueueFull), "Wrong error: %v", err)
+	}
+	assert.True(t, errors.Is(p.ReadTag("other", &unused), ErrQueueFull), "Waiting operations count against the queue size")
+	assert.Equal(t, 2, p.QueueDepth())
+
+	close(brw.release)
+	for _, result := range results {
+		assert.NoError(t, receiveError(t, result))
+	}
+	assert.Equal(t, 0, p.numWaiting(testTagName))
+	assert.Equal(t, 0, p.QueueDepth())
+}
+
+func TestPooledSameTagFloodBlocks(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 1, PoolQueueSize(2))
+	defer p.Close()
+
+	results := []<-chan error{goRead(p, testTagName)}
+	brw.waitForStart(t)
+	for i := 0; i < 20; i++ {
+		results = append(results, goRead(p, testTagName))
+	}
+	for p.numWaiting(testTagName) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The rest block instead of waiting without limit
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 2, p.numWaiting(testTagName))
+	assert.Equal(t, 2, p.QueueDepth())
+
+	// Blocked operations can give up
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var unused int
+	assert.True(t, errors.Is(p.ReadTagContext(ctx, testTagName, &unused), context.DeadlineExceeded))
+
+	close(brw.release)
+	for _, result := range results {
+		assert.NoError(t, receiveError(t, result))
+	}
+	assert.Equal(t, 0, p.numWaiting(testTagName))
+	assert.Equal(t, 0, p.numBlocked(testTagName), "The cancelled operation should no longer be blocked")
+}
+
+func TestPooledSameTagFloodOrder(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 1, PoolQueueSize(1))
+	defer p.Close()
+
+	results := []<-chan error{goWrite(p, testTagName, 0)}
+	brw.waitForStart(t)
+	results = append(results, goWrite(p, testTagName, 1))
+	for p.numWaiting(testTagName) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Operations blocked for room to wait on the tag still run in the order they were submitted
+	for i := 2; i <= 10; i++ {
+		results = append(results, goWrite(p, testTagName, i))
+		for p.numBlocked(testTagName) < i-1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	close(brw.release)
+	for _, result := range results {
+		assert.NoError(t, receiveError(t, result))
+	}
+	for i := 1; i <= 10; i++ {
+		assert.Equal(t, fmt.Sprintf("%s=%d", testTagName, i), brw.waitForStart(t))
+	}
+	assert.Equal(t, 0, p.numBlocked(testTagName))
+}
+
 func BenchmarkSerialPooledOperations(b *testing.B) {
 	benchmarkPooledOperations(b, serialTestConcurrency, 0)
 }
@@ -31,8 +465,8 @@ func benchmarkPooledOperations(b *testing.B, concurrency int, writePerc int) {
 
 	// p exposes concurrent accesses to a mock read/writer, with an artificial
 	// delay introduced between the two of them.
-	// XXX: This leaks goroutines.  So it goes.
 	p := NewPooled(newLatencyIntroducer(newMockReadWriter(), delay), concurrency)
+	defer p.Close()
 
 	read := func(name string) {
 		var garbage uint32
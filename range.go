@@ -0,0 +1,79 @@
+package plc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// RangeReader is the interface that wraps the ReadRange method.
+type RangeReader interface {
+	// ReadRange reads consecutive elements of the named array, starting at index start, into value.
+	// The value must be a pointer to an array or slice, and its length is the number of elements read.
+	// For example, reading into a *[100]float32 with start 100 reads elements 100 to 199.
+	ReadRange(name string, start int, value interface{}) error
+}
+
+// ContextRangeReader is the interface that wraps the ReadRangeContext method.
+type ContextRangeReader interface {
+	// ReadRangeContext is the same as ReadRange, but the read is abandoned if ctx is done first.
+	ReadRangeContext(ctx context.Context, name string, start int, value interface{}) error
+}
+
+// ReadRange reads consecutive elements of the named array from the Reader. See RangeReader for details.
+// If the Reader is a ContextRangeReader or RangeReader and the elements aren't structs or arrays, it
+// is used. Otherwise each element is read separately by a SplitReader.
+func ReadRange(rd Reader, name string, start int, value interface{}) error {
+	return ReadRangeContext(context.Background(), rd, name, start, value)
+}
+
+// ReadRangeContext is the same as ReadRange, but the context is passed to the underlying reads.
+func ReadRangeContext(ctx context.Context, rd Reader, name string, start int, value interface{}) error {
+	if arr, err := RangeElements(name, value); err != nil || !readsRangeAtOnce(rd, arr) {
+		return NewSplitReader(rd).ReadRangeContext(ctx, name, start, value)
+	}
+	if crr, ok := rd.(ContextRangeReader); ok {
+		return crr.ReadRangeContext(ctx, name, start, value)
+	}
+	if rr, ok := rd.(RangeReader); ok {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return rr.ReadRange(name, start, value)
+	}
+	return NewSplitReader(rd).ReadRangeContext(ctx, name, start, value)
+}
+
+// readsRangeAtOnce returns true if the Reader can read the range into arr with one call, which
+// requires it to be a RangeReader or ContextRangeReader and the elements not to be split.
+func readsRangeAtOnce(rd Reader, arr reflect.Value) bool {
+	if !isScalarKind(arr.Type().Elem().Kind()) {
+		return false
+	}
+	_, isRange := rd.(RangeReader)
+	_, isContextRange := rd.(ContextRangeReader)
+	return isRange || isContextRange
+}
+
+// RangeElements returns the array or slice which value points to, or an error if value isn't a
+// pointer to an array or slice. It is useful for implementing RangeReader.
+func RangeElements(name string, value interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr {
+		return reflect.Value{}, ErrNonPointerRead{TagName: name, Kind: v.Kind()}
+	}
+	if v.IsNil() || (v.Elem().Kind() != reflect.Array && v.Elem().Kind() != reflect.Slice) {
+		return reflect.Value{}, fmt.Errorf("%w: a range must be read into a pointer to an array or slice, not %T", ErrBadRequest, value)
+	}
+	return v.Elem(), nil
+}
+
+// isScalarKind returns true if values of the kind aren't split into components by SplitReader.
+func isScalarKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Struct, reflect.Array, reflect.Slice, reflect.Ptr, reflect.Interface:
+		return false
+	default:
+		return true
+	}
+}
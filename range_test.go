@@ -0,0 +1,131 @@
+package plc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRangeReader records its ReadRangeContext calls and fills each element with its index.
+type fakeRangeReader struct {
+	FakeReadWriter
+	calls []string
+}
+
+func (rr *fakeRangeReader) ReadRangeContext(ctx context.Context, name string, start int, value interface{}) error {
+	arr, err := RangeElements(name, value)
+	if err != nil {
+		return err
+	}
+	rr.calls = append(rr.calls, TagWithIndex(name, start))
+	for i := 0; i < arr.Len(); i++ {
+		arr.Index(i).SetInt(int64(start + i))
+	}
+	return nil
+}
+
+func TestReadRangeSplitsWithoutRangeReader(t *testing.T) {
+	fake := FakeReadWriter{"ARR[100]": 7, "ARR[101]": 8, "ARR[102]": 9}
+
+	var actual [3]int
+	require.NoError(t, ReadRange(fake, "ARR", 100, &actual))
+	assert.Equal(t, [3]int{7, 8, 9}, actual)
+
+	slice := make([]int, 2)
+	require.NoError(t, NewSplitReaderParallel(fake).ReadRange("ARR", 101, &slice))
+	assert.Equal(t, []int{8, 9}, slice)
+}
+
+func TestReadRangeUsesRangeReader(t *testing.T) {
+	rr := &fakeRangeReader{}
+
+	actual := make([]int32, 100)
+	require.NoError(t, NewSplitReader(rr).ReadRange("ARR", 100, &actual))
+	assert.Equal(t, []string{"ARR[100]"}, rr.calls, "The whole range should be read with one call")
+	assert.Equal(t, int32(100), actual[0])
+	assert.Equal(t, int32(199), actual[99])
+}
+
+func TestReadRangeSplitsStructsWithRangeReader(t *testing.T) {
+	rr := &fakeRangeReader{FakeReadWriter: FakeReadWriter{"ARR[5].A": 1, "ARR[6].A": 2}}
+
+	actual := make([]struct{ A int }, 2)
+	require.NoError(t, NewSplitReader(rr).ReadRange("ARR", 5, &actual))
+	assert.Empty(t, rr.calls, "Struct elements must be split")
+	assert.Equal(t, 1, actual[0].A)
+	assert.Equal(t, 2, actual[1].A)
+}
+
+func TestReadRangeRequiresPointerToArray(t *testing.T) {
+	var notAPointer [2]int
+	err := ReadRange(FakeReadWriter{}, "ARR", 0, notAPointer)
+	var nonPointerErr ErrNonPointerRead
+	assert.True(t, errors.As(err, &nonPointerErr), "Wrong error: %v", err)
+
+	var notAnArray int
+	err = ReadRange(FakeReadWriter{}, "ARR", 0, &notAnArray)
+	assert.True(t, errors.Is(err, ErrBadRequest), "Wrong error: %v", err)
+	var opErr OpError
+	require.True(t, errors.As(err, &opErr))
+	assert.Equal(t, "ReadRange", opErr.Op)
+}
+
+func TestReadRangeThroughWrappers(t *testing.T) {
+	rr := &fakeRangeReader{}
+	p := NewPooled(NewTagLocker(rr), 2)
+	defer p.Close()
+	cache := NewCache(p)
+	refresher := NewRefresher(cache, time.Hour)
+	defer refresher.Close()
+
+	actual := make([]int32, 100)
+	require.NoError(t, NewSplitReader(refresher).ReadRange("ARR", 100, &actual))
+	assert.Equal(t, []string{"ARR[100]"}, rr.calls, "The whole range should be read with one call")
+	assert.Equal(t, int32(199), actual[99])
+
+	var cached int32
+	require.NoError(t, cache.ReadCachedTag("ARR[150]", &cached), "Each element should be cached")
+	assert.Equal(t, int32(150), cached)
+	assert.Equal(t, 100, refresher.NumTags(), "Each element should be refreshed")
+}
+
+func TestReadRangeThroughPooledUsesAllWorkers(t *testing.T) {
+	brw := newBlockingReadWriter()
+	p := NewPooled(brw, 2)
+	defer p.Close()
+
+	result := make(chan error, 1)
+	go func() {
+		var actual [3]int
+		result <- p.ReadRange("ARR", 0, &actual)
+	}()
+
+	// Both workers are busy at once, and the last element waits for one of them
+	started := []string{brw.waitForStart(t), brw.waitForStart(t)}
+	close(brw.release)
+	started = append(started, brw.waitForStart(t))
+	assert.NoError(t, receiveError(t, result))
+	assert.ElementsMatch(t, []string{"ARR[0]", "ARR[1]", "ARR[2]"}, started)
+}
+
+func TestReadRangeThroughPooledSplitsWithoutRangeReader(t *testing.T) {
+	fake := FakeReadWriter{"ARR[100]": 7, "ARR[101]": 8, "ARR[102]": 9}
+	p := NewPooled(fake, 2)
+	defer p.Close()
+
+	var actual [3]int
+	require.NoError(t, NewSplitReader(p).ReadRange("ARR", 100, &actual))
+	assert.Equal(t, [3]int{7, 8, 9}, actual)
+
+	structs := make([]struct{ A int }, 2)
+	rr := &fakeRangeReader{FakeReadWriter: FakeReadWriter{"ARR[5].A": 1, "ARR[6].A": 2}}
+	p = NewPooled(rr, 1)
+	defer p.Close()
+	require.NoError(t, ReadRange(p, "ARR", 5, &structs))
+	assert.Empty(t, rr.calls, "Struct elements must be split")
+	assert.Equal(t, 2, structs[1].A)
+}
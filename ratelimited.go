@@ -0,0 +1,187 @@
+package plc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimited wraps another ReadWriter and limits how often operations are sent to it.
+// Reads and writes have separate token buckets, so a busy poller can't use up the write budget.
+// Each operation takes one token; if none are available, it waits until one is.
+type RateLimited struct {
+	plc         ReadWriter
+	read, write *tokenBucket
+}
+
+var _ = ReadWriter(RateLimited{})        // Compiler makes sure this type is a ReadWriter
+var _ = ContextReadWriter(RateLimited{}) // Compiler makes sure this type is a ContextReadWriter
+
+// RateLimitedOption configures a RateLimited.
+type RateLimitedOption interface {
+	apply(*RateLimited)
+}
+
+// rateLimitedOptionFunc wraps a func so it satisfies the RateLimitedOption interface.
+type rateLimitedOptionFunc func(*RateLimited)
+
+func (f rateLimitedOptionFunc) apply(rl *RateLimited) { f(rl) }
+
+// RateLimitReads allows perSecond reads on average, with bursts of up to burst reads.
+// By default reads are not limited.
+func RateLimitReads(perSecond float64, burst int) RateLimitedOption {
+	return rateLimitedOptionFunc(func(rl *RateLimited) {
+		rl.read.setRate(perSecond, burst)
+	})
+}
+
+// RateLimitWrites allows perSecond writes on average, with bursts of up to burst writes.
+// By default writes are not limited.
+func RateLimitWrites(perSecond float64, burst int) RateLimitedOption {
+	return rateLimitedOptionFunc(func(rl *RateLimited) {
+		rl.write.setRate(perSecond, burst)
+	})
+}
+
+// NewRateLimited returns a RateLimited which passes operations through to plc.
+func NewRateLimited(plc ReadWriter, opts ...RateLimitedOption) RateLimited {
+	rl := RateLimited{
+		plc:   plc,
+		read:  newTokenBucket(time.Now),
+		write: newTokenBucket(time.Now),
+	}
+	for _, opt := range opts {
+		opt.apply(&rl)
+	}
+	return rl
+}
+
+func (rl RateLimited) ReadTag(name string, value interface{}) error {
+	return rl.ReadTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext waits for a read token, then reads the tag.
+// If ctx is done while waiting, ctx.Err() is returned and the PLC isn't contacted.
+func (rl RateLimited) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	if err := rl.read.wait(ctx); err != nil {
+		return err
+	}
+	return NewContextReader(rl.plc).ReadTagContext(ctx, name, value)
+}
+
+func (rl RateLimited) WriteTag(name string, value interface{}) error {
+	return rl.WriteTagContext(context.Background(), name, value)
+}
+
+// WriteTagContext waits for a write token, then writes the tag.
+// If ctx is done while waiting, ctx.Err() is returned and the PLC isn't contacted.
+func (rl RateLimited) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	if err := rl.write.wait(ctx); err != nil {
+		return err
+	}
+	return NewContextWriter(rl.plc).WriteTagContext(ctx, name, value)
+}
+
+// RateLimitStats describes how operations of one type have been delayed by a RateLimited.
+type RateLimitStats struct {
+	Operations int           // The number of operations which have requested a token
+	Waits      int           // The number of those operations which had to wait
+	WaitTime   time.Duration // The total time spent waiting
+}
+
+// RateLimitedStats holds the RateLimitStats for reads and writes.
+type RateLimitedStats struct {
+	Read, Write RateLimitStats
+}
+
+// Stats returns the wait statistics since the RateLimited was created.
+func (rl RateLimited) Stats() RateLimitedStats {
+	return RateLimitedStats{
+		Read:  rl.read.getStats(),
+		Write: rl.write.getStats(),
+	}
+}
+
+// tokenBucket holds up to burst tokens, and is refilled at rate tokens per second.
+// A rate of 0 means the bucket is unlimited.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64   // May be negative if operations are waiting for future tokens
+	last   time.Time // When tokens was last refilled
+	now    func() time.Time
+	stats  RateLimitStats
+}
+
+func newTokenBucket(now func() time.Time) *tokenBucket {
+	return &tokenBucket{now: now}
+}
+
+func (tb *tokenBucket) setRate(perSecond float64, burst int) {
+	if burst < 1 {
+		burst = 1 // Otherwise no operation could ever proceed without waiting
+	}
+	tb.rate = perSecond
+	tb.burst = float64(burst)
+	tb.tokens = tb.burst
+	tb.last = tb.now()
+}
+
+// wait takes a token, waiting until one is available.
+func (tb *tokenBucket) wait(ctx context.Context) error {
+	delay := tb.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	start := tb.now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	tb.stats.WaitTime += tb.now().Sub(start)
+	if err != nil {
+		tb.tokens++ // The token wasn't used, so give it back
+	}
+	return err
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (tb *tokenBucket) reserve() time.Duration {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.stats.Operations++
+	if tb.rate <= 0 {
+		return 0
+	}
+
+	now := tb.now()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+	tb.stats.Waits++
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+func (tb *tokenBucket) getStats() RateLimitStats {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	return tb.stats
+}
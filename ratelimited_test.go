@@ -0,0 +1,70 @@
+package plc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketBurstAndRefill(t *testing.T) {
+	clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tb := newTokenBucket(clock.now)
+	tb.setRate(10, 3)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, time.Duration(0), tb.reserve(), "Burst should not wait")
+	}
+	assert.Equal(t, 100*time.Millisecond, tb.reserve())
+	assert.Equal(t, 200*time.Millisecond, tb.reserve(), "Each waiting operation reserves a later token")
+
+	clock.Time = clock.Add(time.Second) // Refills 10, but there were 2 tokens owed and the burst is 3
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, time.Duration(0), tb.reserve())
+	}
+	assert.Equal(t, 100*time.Millisecond, tb.reserve())
+
+	assert.Equal(t, RateLimitStats{Operations: 9, Waits: 3}, tb.getStats())
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	tb := newTokenBucket(time.Now)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, time.Duration(0), tb.reserve())
+	}
+}
+
+func TestRateLimitedWaits(t *testing.T) {
+	fakeRW := FakeReadWriter{testTagName: 7}
+	rl := NewRateLimited(fakeRW, RateLimitReads(100, 1))
+
+	var actual int
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, rl.ReadTag(testTagName, &actual))
+	}
+	assert.True(t, time.Since(start) >= 15*time.Millisecond, "Reads after the first should wait about 10ms each")
+
+	// Writes have their own budget, which is unlimited
+	assert.NoError(t, rl.WriteTag(testTagName, 8))
+
+	stats := rl.Stats()
+	assert.Equal(t, 3, stats.Read.Operations)
+	assert.Equal(t, 2, stats.Read.Waits)
+	assert.True(t, stats.Read.WaitTime >= 15*time.Millisecond)
+	assert.Equal(t, RateLimitStats{Operations: 1}, stats.Write)
+}
+
+func TestRateLimitedContextCancelledWhileWaiting(t *testing.T) {
+	rw := &failingReadWriter{FakeReadWriter: FakeReadWriter{testTagName: 7}}
+	rl := NewRateLimited(rw, RateLimitWrites(1.0/3600, 1))
+
+	assert.NoError(t, rl.WriteTag(testTagName, 8))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, rl.WriteTagContext(ctx, testTagName, 9))
+	assert.Equal(t, 1, rw.calls, "The PLC should not be contacted after giving up")
+	assert.Equal(t, 8, rw.FakeReadWriter[testTagName])
+}
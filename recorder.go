@@ -0,0 +1,313 @@
+package plc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Recording is a single operation recorded by a Recorder. Each is stored as one line of JSON.
+type Recording struct {
+	Op         string          `json:"op"` // "ReadTag" or "WriteTag"
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`                  // The Go type which was read or written
+	Value      json.RawMessage `json:"value,omitempty"`       // Omitted if a read failed
+	ValueErr   string          `json:"value_error,omitempty"` // Why the value couldn't be recorded, e.g. a NaN float
+	Err        string          `json:"error,omitempty"`
+	ErrorClass string          `json:"error_class,omitempty"` // See ErrorClass
+	Deadline   bool            `json:"deadline,omitempty"`    // For the "context" class, whether it was a deadline rather than a cancellation
+	Time       time.Time       `json:"time"`
+	Duration   time.Duration   `json:"duration"` // How long the operation took, which a Replayer can reproduce
+}
+
+// Recorder wraps another ReadWriter and records every operation to an io.Writer as JSON Lines.
+// The recording can be played back with a Replayer.
+type Recorder struct {
+	plc   ReadWriter
+	mutex sync.Mutex
+	enc   *json.Encoder
+	err   error
+}
+
+var _ = ReadWriter(&Recorder{})        // Compiler makes sure this type is a ReadWriter
+var _ = ContextReadWriter(&Recorder{}) // Compiler makes sure this type is a ContextReadWriter
+
+// NewRecorder returns a Recorder which passes operations through to plc and records them to w.
+func NewRecorder(plc ReadWriter, w io.Writer) *Recorder {
+	return &Recorder{
+		plc: plc,
+		enc: json.NewEncoder(w),
+	}
+}
+
+// Err returns the first error which occurred while writing the recording, if any.
+// Such errors don't cause operations to fail. A value which can't be marshalled isn't an error
+// here; it is noted in the Recording's ValueErr instead.
+func (rec *Recorder) Err() error {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return rec.err
+}
+
+func (rec *Recorder) ReadTag(name string, value interface{}) error {
+	return rec.ReadTagContext(context.Background(), name, value)
+}
+
+func (rec *Recorder) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	start := time.Now()
+	err := NewContextReader(rec.plc).ReadTagContext(ctx, name, value)
+	rec.record("ReadTag", name, value, start, err)
+	return err
+}
+
+func (rec *Recorder) WriteTag(name string, value interface{}) error {
+	return rec.WriteTagContext(context.Background(), name, value)
+}
+
+func (rec *Recorder) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	start := time.Now()
+	err := NewContextWriter(rec.plc).WriteTagContext(ctx, name, value)
+	rec.record("WriteTag", name, value, start, err)
+	return err
+}
+
+func (rec *Recorder) record(op, name string, value interface{}, start time.Time, opErr error) {
+	r := Recording{
+		Op:       op,
+		Name:     name,
+		Type:     recordedType(op, value),
+		Time:     start,
+		Duration: time.Since(start),
+	}
+	if opErr != nil {
+		r.Err = opErr.Error()
+		r.ErrorClass = ErrorClass(opErr)
+		r.Deadline = errors.Is(opErr, context.DeadlineExceeded)
+	}
+
+	if opErr == nil || op == "WriteTag" {
+		// The operation is still recorded if its value can't be, so the replay stays in order
+		var err error
+		if r.Value, err = json.Marshal(value); err != nil {
+			r.ValueErr = err.Error()
+		}
+	}
+
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if err := rec.enc.Encode(r); err != nil && rec.err == nil {
+		rec.err = err
+	}
+}
+
+// recordedType returns the name of the type being read or written.
+// For reads, that's the type which the pointer points to.
+func recordedType(op string, value interface{}) string {
+	typ := reflect.TypeOf(value)
+	if typ == nil {
+		return "<nil>"
+	}
+	if op == "ReadTag" && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.String()
+}
+
+// Replayer is a ReadWriter which plays back a recording made by a Recorder. Operations on each tag
+// must be requested in the same order as they were recorded, with the same types and written
+// values. Otherwise a ReplayError is returned. Operations on different tags may be requested in
+// any order, so a recording made behind a parallel layer, such as Pooled or
+// NewSplitReaderParallel, replays reliably.
+type Replayer struct {
+	mutex      sync.Mutex
+	recordings []Recording
+	next       map[string][]int // For each tag, the indices of the recordings which haven't been replayed
+	remaining  int
+
+	// ReplayDurations causes each operation to take as long as it did when it was recorded.
+	// By default, operations return immediately.
+	ReplayDurations bool
+}
+
+var _ = ReadWriter(&Replayer{}) // Compiler makes sure this type is a ReadWriter
+
+// NewReplayer reads a recording made by a Recorder.
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	rp := &Replayer{next: map[string][]int{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024) // Recordings of large structs can be long
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec Recording
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("NewReplayer line %d: %w", line, err)
+		}
+		rp.next[rec.Name] = append(rp.next[rec.Name], len(rp.recordings))
+		rp.recordings = append(rp.recordings, rec)
+	}
+	rp.remaining = len(rp.recordings)
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("NewReplayer: %w", err)
+	}
+	return rp, nil
+}
+
+// Remaining returns the number of recorded operations which haven't been replayed yet.
+// A test should usually check that it's 0 at the end.
+func (rp *Replayer) Remaining() int {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	return rp.remaining
+}
+
+// ReadTag returns the recorded value or error.
+func (rp *Replayer) ReadTag(name string, value interface{}) error {
+	rec, err := rp.expect("ReadTag", name, value)
+	if err != nil {
+		return err
+	}
+	rp.wait(rec)
+	if rec.Err != "" {
+		return replayedError{rec.Err, rec.ErrorClass, rec.Deadline}
+	}
+	if rec.ValueErr != "" {
+		return fmt.Errorf("%w: replaying ReadTag '%s': the value wasn't recorded: %s", ErrBadRequest, name, rec.ValueErr)
+	}
+	if err := json.Unmarshal(rec.Value, value); err != nil {
+		return fmt.Errorf("%w: replaying ReadTag '%s': %v", ErrBadRequest, name, err)
+	}
+	return nil
+}
+
+// WriteTag checks the value is the same as was recorded, and returns the recorded error.
+func (rp *Replayer) WriteTag(name string, value interface{}) error {
+	rec, err := rp.expect("WriteTag", name, value)
+	if err != nil {
+		return err
+	}
+	rp.wait(rec)
+	if rec.Err != "" {
+		return replayedError{rec.Err, rec.ErrorClass, rec.Deadline}
+	}
+	return nil
+}
+
+// wait sleeps for the recorded duration if ReplayDurations is set.
+func (rp *Replayer) wait(rec Recording) {
+	if rp.ReplayDurations {
+		time.Sleep(rec.Duration)
+	}
+}
+
+// expect returns the next recording of the tag if it matches the operation. For writes, the value
+// must also match, unless the recorded value couldn't be recorded and it can't be marshalled now
+// either. Otherwise the recording isn't used up.
+func (rp *Replayer) expect(op, name string, value interface{}) (Recording, error) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+
+	next := rp.next[name]
+	replayErr := ReplayError{Index: len(rp.recordings), Op: op, Name: name}
+	if len(next) == 0 {
+		return Recording{}, replayErr
+	}
+
+	replayErr.Index = next[0]
+	rec := rp.recordings[next[0]]
+	replayErr.Expected = rec
+	if rec.Op != op || rec.Type != recordedType(op, value) {
+		return Recording{}, replayErr
+	}
+
+	if op == "WriteTag" {
+		actual, err := json.Marshal(value)
+		switch {
+		case rec.ValueErr != "" && err != nil:
+			rp.use(name)
+			return rec, nil // Neither value can be compared
+		case rec.ValueErr != "":
+			replayErr.Value = string(actual)
+			return Recording{}, replayErr
+		case err != nil:
+			return Recording{}, fmt.Errorf("%w: replaying WriteTag '%s': %v", ErrBadRequest, name, err)
+		}
+		var expected bytes.Buffer
+		if err := json.Compact(&expected, rec.Value); err != nil || !bytes.Equal(expected.Bytes(), actual) {
+			replayErr.Value = string(actual)
+			return Recording{}, replayErr
+		}
+	}
+
+	rp.use(name)
+	return rec, nil
+}
+
+// use marks the tag's next recording as replayed. The caller must hold the mutex.
+func (rp *Replayer) use(name string) {
+	rp.next[name] = rp.next[name][1:]
+	rp.remaining--
+}
+
+// ReplayError is returned by a Replayer if the requested operation is not the next one recorded for its tag.
+type ReplayError struct {
+	Index    int       // The index of the expected recording, or the number of recordings if there were no more
+	Expected Recording // The expected recording, which is empty if there were no more for the tag
+	Op, Name string    // The operation which was requested
+	Value    string    // For a write with an unexpected value, the JSON of that value
+}
+
+func (err ReplayError) Error() string {
+	if err.Expected.Op == "" {
+		return fmt.Sprintf("Replay %d: unexpected %s '%s' after the end of the tag's recording", err.Index, err.Op, err.Name)
+	}
+	if err.Value != "" && err.Expected.ValueErr != "" {
+		return fmt.Sprintf("Replay %d: expected %s '%s' of an unrecorded value (%s), but got %s", err.Index, err.Expected.Op, err.Expected.Name, err.Expected.ValueErr, err.Value)
+	}
+	if err.Value != "" {
+		return fmt.Sprintf("Replay %d: expected %s '%s' of %s, but got %s", err.Index, err.Expected.Op, err.Expected.Name, err.Expected.Value, err.Value)
+	}
+	return fmt.Sprintf("Replay %d: expected %s '%s' (%s), but got %s '%s'", err.Index, err.Expected.Op, err.Expected.Name, err.Expected.Type, err.Op, err.Name)
+}
+
+func (err ReplayError) Unwrap() error { return ErrBadRequest }
+
+// replayedError is a recorded error. It unwraps to the sentinel for its ErrorClass.
+type replayedError struct {
+	msg, class string
+	deadline   bool // For the "context" class, whether to unwrap to context.DeadlineExceeded
+}
+
+func (err replayedError) Error() string { return err.msg }
+
+func (err replayedError) Unwrap() error {
+	switch err.class {
+	case "context":
+		if err.deadline {
+			return context.DeadlineExceeded
+		}
+		return context.Canceled
+	case "bad_request":
+		return ErrBadRequest
+	case "connection":
+		return ErrPlcConnection
+	case "internal":
+		return ErrPlcInternal
+	case "closed":
+		return ErrClosed
+	case "queue_full":
+		return ErrQueueFull
+	case "circuit_open":
+		return ErrCircuitOpen
+	default:
+		return nil
+	}
+}
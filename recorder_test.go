@@ -0,0 +1,171 @@
+package plc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderAndReplayer(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(FakeReadWriter{testTagName: uint16(7)}, &buf)
+
+	var val uint16
+	require.NoError(t, rec.ReadTag(testTagName, &val))
+	require.NoError(t, rec.WriteTag(testTagName, fakeDBMotor{Speed: 2, Faults: [3]int16{1}}))
+	readErr := rec.ReadTag("MISSING", &val)
+	require.Error(t, readErr)
+	require.NoError(t, rec.Err())
+	assert.Equal(t, 3, strings.Count(buf.String(), "\n"))
+
+	rp, err := NewReplayer(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rp.Remaining())
+
+	var replayed uint16
+	require.NoError(t, rp.ReadTag(testTagName, &replayed))
+	assert.Equal(t, uint16(7), replayed)
+	require.NoError(t, rp.WriteTag(testTagName, fakeDBMotor{Speed: 2, Faults: [3]int16{1}}))
+	err = rp.ReadTag("MISSING", &replayed)
+	assert.EqualError(t, err, readErr.Error())
+	assert.Equal(t, 0, rp.Remaining())
+}
+
+func TestReplayerReproducesErrorClass(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&failingReadWriter{failures: 1, err: errTestTransient}, &buf)
+	require.Error(t, rec.WriteTag(testTagName, 5))
+
+	rp, err := NewReplayer(&buf)
+	require.NoError(t, err)
+	err = rp.WriteTag(testTagName, 5)
+	assert.True(t, errors.Is(err, ErrPlcConnection), "Error should be a connection error: %v", err)
+}
+
+func TestReplayerUnexpectedRequests(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(FakeReadWriter{}, &buf)
+	require.NoError(t, rec.WriteTag(testTagName, 5))
+
+	rp, err := NewReplayer(&buf)
+	require.NoError(t, err)
+
+	var replayErr ReplayError
+	var val int
+	err = rp.ReadTag(testTagName, &val)
+	require.True(t, errors.As(err, &replayErr), "Wrong operation should fail: %v", err)
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	assert.Equal(t, "WriteTag", replayErr.Expected.Op)
+
+	err = rp.WriteTag("OTHER_TAG", 5)
+	assert.True(t, errors.As(err, &replayErr), "Wrong name should fail: %v", err)
+
+	err = rp.WriteTag(testTagName, int16(5))
+	assert.True(t, errors.As(err, &replayErr), "Wrong type should fail: %v", err)
+
+	err = rp.WriteTag(testTagName, 6)
+	require.True(t, errors.As(err, &replayErr), "Wrong value should fail: %v", err)
+	assert.Equal(t, "6", replayErr.Value)
+	assert.Equal(t, 1, rp.Remaining(), "Failed requests shouldn't use up the recording")
+
+	require.NoError(t, rp.WriteTag(testTagName, 5))
+	err = rp.WriteTag(testTagName, 5)
+	require.True(t, errors.As(err, &replayErr), "Requests after the end should fail: %v", err)
+	assert.Equal(t, 1, replayErr.Index)
+}
+
+func TestRecorderUnmarshallableValue(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(FakeReadWriter{"REAL": math.NaN(), testTagName: 7}, &buf)
+
+	var real float64
+	var val int
+	require.NoError(t, rec.ReadTag("REAL", &real))
+	require.NoError(t, rec.WriteTag("REAL", math.Inf(1)))
+	require.NoError(t, rec.ReadTag(testTagName, &val))
+	assert.NoError(t, rec.Err())
+	assert.Equal(t, 3, strings.Count(buf.String(), "\n"), "Every operation should be recorded")
+
+	rp, err := NewReplayer(&buf)
+	require.NoError(t, err)
+	err = rp.ReadTag("REAL", &real)
+	assert.True(t, errors.Is(err, ErrBadRequest), "A value which wasn't recorded can't be replayed: %v", err)
+	assert.Contains(t, err.Error(), "NaN")
+
+	var replayErr ReplayError
+	assert.True(t, errors.As(rp.WriteTag("REAL", 1.0), &replayErr), "A recordable value doesn't match")
+	assert.NoError(t, rp.WriteTag("REAL", math.Inf(1)))
+	require.NoError(t, rp.ReadTag(testTagName, &val), "Later operations should still replay")
+	assert.Equal(t, 7, val)
+}
+
+func TestReplayerReproducesContextError(t *testing.T) {
+	var buf bytes.Buffer
+	var ctxErr error
+	rec := NewRecorder(struct {
+		Reader
+		Writer
+	}{FakeReadWriter{}, writerFunc(func(string, interface{}) error { return ctxErr })}, &buf)
+	ctxErr = context.DeadlineExceeded
+	require.Error(t, rec.WriteTag(testTagName, 1))
+	ctxErr = context.Canceled
+	require.Error(t, rec.WriteTag(testTagName, 2))
+
+	rp, err := NewReplayer(&buf)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(rp.WriteTag(testTagName, 1), context.DeadlineExceeded))
+	assert.True(t, errors.Is(rp.WriteTag(testTagName, 2), context.Canceled))
+}
+
+func TestReplayerOrdersEachTagSeparately(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(FakeReadWriter{}, &buf)
+	require.NoError(t, rec.WriteTag("A", 1))
+	require.NoError(t, rec.WriteTag("B", 2))
+	require.NoError(t, rec.WriteTag("A", 3))
+
+	rp, err := NewReplayer(&buf)
+	require.NoError(t, err)
+
+	// A parallel layer might run the operations on different tags in another order
+	require.NoError(t, rp.WriteTag("A", 1))
+	require.NoError(t, rp.WriteTag("A", 3))
+	require.NoError(t, rp.WriteTag("B", 2))
+	assert.Equal(t, 0, rp.Remaining())
+
+	// But operations on the same tag must stay in order
+	buf.Reset()
+	require.NoError(t, rec.WriteTag("A", 1))
+	require.NoError(t, rec.WriteTag("A", 3))
+	rp, err = NewReplayer(&buf)
+	require.NoError(t, err)
+	var replayErr ReplayError
+	require.True(t, errors.As(rp.WriteTag("A", 3), &replayErr))
+	assert.Equal(t, 0, replayErr.Index)
+}
+
+func TestReplayerDurations(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(newLatencyIntroducer(FakeReadWriter{}, 20*time.Millisecond), &buf)
+	require.NoError(t, rec.WriteTag(testTagName, 1))
+
+	rp, err := NewReplayer(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	start := time.Now()
+	require.NoError(t, rp.WriteTag(testTagName, 1))
+	assert.Less(t, int64(time.Since(start)), int64(20*time.Millisecond), "By default, durations aren't replayed")
+
+	rp, err = NewReplayer(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	rp.ReplayDurations = true
+	start = time.Now()
+	require.NoError(t, rp.WriteTag(testTagName, 1))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(20*time.Millisecond))
+}
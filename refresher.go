@@ -1,6 +1,8 @@
 package plc
 
 import (
+	"context"
+	"fmt"
 	"reflect"
 	"sync"
 	"time"
@@ -8,10 +10,17 @@ import (
 
 // A Refresher can be used to periodically reissue the read for every seen value, so that values are readily available in a cache.
 type Refresher struct {
-	plc    Reader
-	period time.Duration
-	seen   map[string]struct{}
-	mutex  sync.Mutex
+	plc       Reader
+	period    time.Duration
+	periods   map[string]time.Duration // Periods for tags which don't use the default
+	seen      map[string]*refreshedTag
+	nextSubID int
+	closed    bool
+	mutex     sync.Mutex
+
+	// ctx is passed to every refresh, so in-progress refreshes are abandoned on Close.
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	// ErrorCallback is called if an error is encountered while refreshing.
 	// If no callback is set, the error is silently discarded (and you're a bad
@@ -19,41 +28,299 @@ type Refresher struct {
 	ErrorCallback func(error)
 }
 
-var _ = Reader(&Refresher{}) // Compiler makes sure this type is a Reader
+// refreshedTag holds the state of a tag which is being refreshed.
+// It is protected by the Refresher's mutex.
+type refreshedTag struct {
+	typ         reflect.Type  // The type which is refreshed
+	period      time.Duration // How often to refresh
+	stop        chan struct{} // Closed to stop refreshing
+	done        chan struct{} // Closed once the goroutine refreshing until stop has returned
+	last        interface{}   // The most recently refreshed value
+	hasLast     bool          // False until the first successful refresh
+	subscribers map[int]subscriber
+}
+
+// subscriber receives changes. The stop channel is closed when the goroutine delivering the
+// change stops refreshing, and done is closed on Unsubscribe, so a subscriber which blocks can give up.
+type subscriber struct {
+	deliver func(change Change, stop, done <-chan struct{})
+	done    chan struct{}
+}
+
+var _ = Reader(&Refresher{})             // Compiler makes sure this type is a Reader
+var _ = ContextReader(&Refresher{})      // Compiler makes sure this type is a ContextReader
+var _ = Closer(&Refresher{})             // Compiler makes sure this type is a Closer
+var _ = ContextRangeReader(&Refresher{}) // Compiler makes sure this type is a ContextRangeReader
 
 // NewRefresher returns a refresher that will update every read value.
+// By default, every tag is refreshed with the provided period. SetPeriod can be used to
+// refresh some tags more or less frequently.
+// Close should be called to stop refreshing.
 func NewRefresher(plc Reader, period time.Duration) *Refresher {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Refresher{
-		plc:    plc,
-		period: period,
-		seen:   map[string]struct{}{},
+		plc:     plc,
+		period:  period,
+		periods: map[string]time.Duration{},
+		seen:    map[string]*refreshedTag{},
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 }
 
-func (r *Refresher) launchIfNecessary(name string, value interface{}, f func(v interface{})) {
+// Close stops refreshing all tags. Refreshes which are already in progress are abandoned
+// if the underlying Reader is a ContextReader; otherwise they might complete after Close returns.
+// After Close, reads still pass through to the underlying Reader, but nothing is refreshed.
+// Close always returns nil, and it is safe to call more than once.
+func (r *Refresher) Close() error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	if _, ok := r.seen[name]; ok {
-		return
+
+	r.closed = true
+	r.cancel()
+	for name, tag := range r.seen {
+		close(tag.stop)
+		delete(r.seen, name)
+	}
+	return nil
+}
+
+// Forget stops refreshing the named tag, and its subscriptions will no longer receive changes.
+// If the tag is read or subscribed to again, refreshing begins again.
+func (r *Refresher) Forget(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if tag, ok := r.seen[name]; ok {
+		close(tag.stop)
+		delete(r.seen, name)
+	}
+}
+
+// SetPeriod sets how often the named tag is refreshed, which allows tags to be grouped
+// into faster and slower scan classes. If the tag is already being refreshed, its period
+// is changed. Otherwise the period is used once it is read. A period of 0 restores the default.
+// A negative period is rejected with ErrBadRequest.
+func (r *Refresher) SetPeriod(name string, period time.Duration) error {
+	if period < 0 {
+		return fmt.Errorf("%w: negative refresh period %v for '%s'", ErrBadRequest, period, name)
 	}
 
-	r.seen[name] = struct{}{}
-	value = reflect.New(reflect.TypeOf(value).Elem()).Interface()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if period == 0 {
+		delete(r.periods, name)
+		period = r.period
+	} else {
+		r.periods[name] = period
+	}
+
+	tag, ok := r.seen[name]
+	if !ok || tag.period == period {
+		return nil
+	}
 
-	go func() {
-		for _ = range time.NewTicker(r.period).C {
-			f(value)
+	// Restart the refresh goroutine with the new period once the old one (and its refresh) is done
+	close(tag.stop)
+	prev := tag.done
+	tag.period = period
+	tag.stop = make(chan struct{})
+	tag.done = make(chan struct{})
+	go r.run(name, tag, tag.period, tag.stop, prev, tag.done)
+	return nil
+}
+
+// NumTags returns the number of tags currently being refreshed.
+func (r *Refresher) NumTags() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.seen)
+}
+
+// launchIfNecessary begins refreshing the named tag if it isn't already being refreshed.
+// The value is a pointer to the type that should be refreshed.
+// If the Refresher has been closed, the returned tag is never refreshed.
+// The caller must hold the mutex.
+func (r *Refresher) launchIfNecessary(name string, value interface{}) *refreshedTag {
+	if tag, ok := r.seen[name]; ok {
+		return tag
+	}
+
+	tag := &refreshedTag{
+		typ:         reflect.TypeOf(value).Elem(),
+		period:      r.period,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		subscribers: map[int]subscriber{},
+	}
+	if period, ok := r.periods[name]; ok {
+		tag.period = period
+	}
+	if r.closed {
+		return tag
+	}
+
+	r.seen[name] = tag
+	go r.run(name, tag, tag.period, tag.stop, nil, tag.done)
+
+	return tag
+}
+
+// run refreshes the tag every period until stop is closed, and then closes done.
+// If prev isn't nil, refreshing doesn't begin until it is closed by the goroutine being replaced.
+// The channels and period are passed in because the tag's fields may be replaced by SetPeriod.
+func (r *Refresher) run(name string, tag *refreshedTag, period time.Duration, stop <-chan struct{}, prev <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	if prev != nil {
+		select {
+		case <-prev:
+		case <-stop:
+			return
+		}
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.refresh(name, tag, stop)
 		}
-	}()
+	}
 }
 
-func (r *Refresher) ReadTag(name string, value interface{}) error {
-	r.launchIfNecessary(name, value, func(v interface{}) {
-		err := r.plc.ReadTag(name, v)
-		if err != nil && r.ErrorCallback != nil {
+// refresh reads a new value and notifies subscribers if it has changed.
+// The stop channel is the one for the goroutine which is refreshing.
+func (r *Refresher) refresh(name string, tag *refreshedTag, stop <-chan struct{}) {
+	value := reflect.New(tag.typ)
+	err := NewContextReader(r.plc).ReadTagContext(r.ctx, name, value.Interface())
+	if err != nil {
+		if r.ctx.Err() != nil {
+			return // The refresher was closed, so the error is expected
+		}
+		if r.ErrorCallback != nil {
 			r.ErrorCallback(err)
 		}
+		return
+	}
+
+	newVal := value.Elem().Interface()
+
+	r.mutex.Lock()
+	if tag.hasLast && reflect.DeepEqual(tag.last, newVal) {
+		r.mutex.Unlock()
+		return // Nothing changed
+	}
+	tag.last, tag.hasLast = newVal, true
+	subscribers := make([]subscriber, 0, len(tag.subscribers))
+	for _, sub := range tag.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	r.mutex.Unlock()
+
+	for _, sub := range subscribers {
+		sub.deliver(Change{Name: name, Value: newVal}, stop, sub.done)
+	}
+}
+
+func (r *Refresher) ReadTag(name string, value interface{}) error {
+	return r.ReadTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext reads the tag and, if the tag hasn't been seen before, begins refreshing it.
+// The context only applies to this read, not to the refreshes.
+func (r *Refresher) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	r.mutex.Lock()
+	r.launchIfNecessary(name, value)
+	r.mutex.Unlock()
+
+	return NewContextReader(r.plc).ReadTagContext(ctx, name, value)
+}
+
+func (r *Refresher) ReadRange(name string, start int, value interface{}) error {
+	return r.ReadRangeContext(context.Background(), name, start, value)
+}
+
+// ReadRangeContext reads a range of the named array, with one call if the underlying Reader is
+// a RangeReader. Each element which hasn't been seen before begins refreshing separately, just
+// as if it had been read with ReadTag. The context only applies to this read, not to the refreshes.
+func (r *Refresher) ReadRangeContext(ctx context.Context, name string, start int, value interface{}) error {
+	arr, err := RangeElements(name, value)
+	if err != nil {
+		return WrapOpError(err, "Refresher", "ReadRange", name)
+	}
+
+	r.mutex.Lock()
+	for i := 0; i < arr.Len(); i++ {
+		r.launchIfNecessary(TagWithIndex(name, start+i), arr.Index(i).Addr().Interface())
+	}
+	r.mutex.Unlock()
+
+	return ReadRangeContext(ctx, r.plc, name, start, value)
+}
+
+// Change describes a new value read by a Refresher.
+type Change struct {
+	Name  string
+	Value interface{} // The new value (not a pointer to it)
+}
+
+// Subscription is returned by Subscribe and can be used to stop receiving changes.
+type Subscription struct {
+	refresher *Refresher
+	tag       *refreshedTag
+	id        int
+}
+
+// Subscribe calls callback every time a refresh of the named tag reads a value which differs
+// from the previous refresh. The first successful refresh is always considered to be a change.
+// As with ReadTag, value must be a pointer to the type to read. If the tag is already being
+// refreshed, the existing type is used instead.
+// If the tag isn't already being refreshed, it begins refreshing. (No immediate read is made.)
+// The callback is called on the tag's refresh goroutine, so it should return quickly.
+func (r *Refresher) Subscribe(name string, value interface{}, callback func(Change)) *Subscription {
+	return r.subscribe(name, value, func(change Change, _, _ <-chan struct{}) {
+		callback(change)
 	})
+}
 
-	return r.plc.ReadTag(name, value)
+// SubscribeChannel is the same as Subscribe, but each change is sent on ch.
+// Refreshing of this tag is blocked while ch is full, so it should be buffered or drained promptly.
+// If refreshing stops because of Close, Forget, or SetPeriod, or the subscription is unsubscribed
+// while a change is blocked, the change is dropped.
+func (r *Refresher) SubscribeChannel(name string, value interface{}, ch chan<- Change) *Subscription {
+	return r.subscribe(name, value, func(change Change, stop, done <-chan struct{}) {
+		select {
+		case ch <- change:
+		case <-stop:
+		case <-done:
+		case <-r.ctx.Done():
+		}
+	})
+}
+
+func (r *Refresher) subscribe(name string, value interface{}, deliver func(Change, <-chan struct{}, <-chan struct{})) *Subscription {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tag := r.launchIfNecessary(name, value)
+	r.nextSubID++
+	tag.subscribers[r.nextSubID] = subscriber{deliver: deliver, done: make(chan struct{})}
+	return &Subscription{refresher: r, tag: tag, id: r.nextSubID}
+}
+
+// Unsubscribe stops the subscription. A change which is already being delivered might still arrive,
+// but a SubscribeChannel send which is blocked is abandoned.
+// It is safe to call Unsubscribe more than once.
+func (sub *Subscription) Unsubscribe() {
+	sub.refresher.mutex.Lock()
+	defer sub.refresher.mutex.Unlock()
+	if s, ok := sub.tag.subscribers[sub.id]; ok {
+		close(s.done)
+		delete(sub.tag.subscribers, sub.id)
+	}
 }
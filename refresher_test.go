@@ -0,0 +1,259 @@
+package plc
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRefreshPeriod = time.Millisecond
+
+// sequenceReader returns each of its values in turn, then repeats the last one forever.
+type sequenceReader struct {
+	values []int
+	reads  int
+	mutex  sync.Mutex
+}
+
+func (sr *sequenceReader) ReadTag(name string, value interface{}) error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	idx := sr.reads
+	if idx >= len(sr.values) {
+		idx = len(sr.values) - 1
+	}
+	sr.reads++
+	*value.(*int) = sr.values[idx]
+	return nil
+}
+
+func (sr *sequenceReader) numReads() int {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	return sr.reads
+}
+
+func receiveChanges(t *testing.T, ch <-chan Change, num int) []interface{} {
+	var values []interface{}
+	for i := 0; i < num; i++ {
+		select {
+		case change := <-ch:
+			assert.Equal(t, testTagName, change.Name)
+			values = append(values, change.Value)
+		case <-time.After(time.Second):
+			require.FailNow(t, "Timeout waiting for a change")
+		}
+	}
+	return values
+}
+
+func TestRefresherSubscribeOnlyChanges(t *testing.T) {
+	rd := &sequenceReader{values: []int{1, 1, 2, 2, 2, 3}}
+	r := NewRefresher(rd, testRefreshPeriod)
+
+	ch := make(chan Change, 10)
+	r.SubscribeChannel(testTagName, new(int), ch)
+
+	assert.Equal(t, []interface{}{1, 2, 3}, receiveChanges(t, ch, 3))
+
+	// Wait for a few more refreshes, which should not be reported since the value stays the same
+	for reads := rd.numReads(); rd.numReads() < reads+3; {
+		time.Sleep(testRefreshPeriod)
+	}
+	assert.Len(t, ch, 0, "Unchanged values should not be reported")
+}
+
+func TestRefresherUnsubscribe(t *testing.T) {
+	rd := &sequenceReader{values: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}
+	r := NewRefresher(rd, testRefreshPeriod)
+
+	stayCh := make(chan Change, 10)
+	r.SubscribeChannel(testTagName, new(int), stayCh)
+
+	leaveCh := make(chan Change, 10)
+	sub := r.SubscribeChannel(testTagName, new(int), leaveCh)
+
+	receiveChanges(t, leaveCh, 1)
+	sub.Unsubscribe()
+	sub.Unsubscribe() // Calling twice is safe
+
+	receiveChanges(t, stayCh, 5)
+	assert.LessOrEqual(t, len(leaveCh), 1, "At most one change should arrive after unsubscribing")
+}
+
+func TestRefresherSubscribeCallback(t *testing.T) {
+	rd := &sequenceReader{values: []int{4}}
+	r := NewRefresher(rd, testRefreshPeriod)
+
+	ch := make(chan Change, 10)
+	r.Subscribe(testTagName, new(int), func(change Change) {
+		ch <- change
+	})
+	assert.Equal(t, []interface{}{4}, receiveChanges(t, ch, 1))
+}
+
+// countingReader counts the reads of each tag.
+type countingReader struct {
+	counts map[string]int
+	mutex  sync.Mutex
+}
+
+func newCountingReader() *countingReader {
+	return &countingReader{counts: map[string]int{}}
+}
+
+func (cr *countingReader) ReadTag(name string, value interface{}) error {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.counts[name]++
+	return nil
+}
+
+func (cr *countingReader) count(name string) int {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	return cr.counts[name]
+}
+
+// waitForReads waits until the tag has been read at least num more times.
+func (cr *countingReader) waitForReads(t *testing.T, name string, num int) {
+	target := cr.count(name) + num
+	for start := time.Now(); cr.count(name) < target; time.Sleep(testRefreshPeriod) {
+		require.True(t, time.Since(start) < time.Second, "Timeout waiting for %s to be refreshed", name)
+	}
+}
+
+func TestRefresherClose(t *testing.T) {
+	cr := newCountingReader()
+	r := NewRefresher(cr, testRefreshPeriod)
+
+	require.NoError(t, r.ReadTag(testTagName, new(int)))
+	cr.waitForReads(t, testTagName, 2)
+	assert.Equal(t, 1, r.NumTags())
+
+	assert.NoError(t, r.Close())
+	assert.NoError(t, r.Close(), "Second Close should be safe")
+	assert.Equal(t, 0, r.NumTags())
+
+	time.Sleep(5 * testRefreshPeriod) // Let any in-progress refresh finish
+	reads := cr.count(testTagName)
+	time.Sleep(10 * testRefreshPeriod)
+	assert.Equal(t, reads, cr.count(testTagName), "No refreshes should happen after Close")
+
+	// Reads still work, but don't launch refreshing
+	require.NoError(t, r.ReadTag(testTagName, new(int)))
+	assert.Equal(t, 0, r.NumTags())
+}
+
+func TestRefresherCloseWithBlockedSubscriber(t *testing.T) {
+	before := runtime.NumGoroutine()
+	rd := &sequenceReader{values: []int{1}}
+	r := NewRefresher(rd, testRefreshPeriod)
+
+	r.SubscribeChannel(testTagName, new(int), make(chan Change)) // Nobody receives
+	for rd.numReads() < 1 {
+		time.Sleep(testRefreshPeriod)
+	}
+	time.Sleep(5 * testRefreshPeriod) // Let the refresh goroutine block on the send
+
+	require.NoError(t, r.Close())
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			require.FailNow(t, "The refresh goroutine should exit after Close")
+		}
+		time.Sleep(testRefreshPeriod)
+	}
+}
+
+func TestRefresherUnsubscribeWithBlockedSubscriber(t *testing.T) {
+	cr := newCountingReader()
+	r := NewRefresher(cr, testRefreshPeriod)
+	defer r.Close()
+
+	sub := r.SubscribeChannel(testTagName, new(int), make(chan Change)) // Nobody receives
+	cr.waitForReads(t, testTagName, 1)
+	time.Sleep(5 * testRefreshPeriod) // Let the refresh goroutine block on the send
+
+	sub.Unsubscribe()
+	cr.waitForReads(t, testTagName, 3)
+}
+
+func TestRefresherForget(t *testing.T) {
+	cr := newCountingReader()
+	r := NewRefresher(cr, testRefreshPeriod)
+	defer r.Close()
+
+	require.NoError(t, r.ReadTag(testTagName, new(int)))
+	require.NoError(t, r.ReadTag(secondTestTagName, new(int)))
+	cr.waitForReads(t, testTagName, 2)
+
+	r.Forget(testTagName)
+	assert.Equal(t, 1, r.NumTags())
+	time.Sleep(5 * testRefreshPeriod) // Let any in-progress refresh finish
+	reads := cr.count(testTagName)
+	cr.waitForReads(t, secondTestTagName, 5)
+	assert.Equal(t, reads, cr.count(testTagName), "Forgotten tag should not be refreshed")
+
+	// Reading again starts refreshing again
+	require.NoError(t, r.ReadTag(testTagName, new(int)))
+	cr.waitForReads(t, testTagName, 2)
+}
+
+func TestRefresherSetPeriod(t *testing.T) {
+	cr := newCountingReader()
+	r := NewRefresher(cr, time.Hour)
+	defer r.Close()
+
+	// Set the period before the tag is read
+	require.NoError(t, r.SetPeriod(testTagName, testRefreshPeriod))
+	require.NoError(t, r.ReadTag(testTagName, new(int)))
+	cr.waitForReads(t, testTagName, 3)
+
+	// Change the period of a tag which is already refreshing
+	require.NoError(t, r.ReadTag(secondTestTagName, new(int)))
+	require.NoError(t, r.SetPeriod(secondTestTagName, testRefreshPeriod))
+	cr.waitForReads(t, secondTestTagName, 3)
+
+	// Restore the default
+	require.NoError(t, r.SetPeriod(secondTestTagName, 0))
+	time.Sleep(5 * testRefreshPeriod) // Let any in-progress refresh finish
+	reads := cr.count(secondTestTagName)
+	cr.waitForReads(t, testTagName, 5)
+	assert.Equal(t, reads, cr.count(secondTestTagName), "Tag should return to the slow default period")
+}
+
+func TestRefresherSetPeriodRejectsNegative(t *testing.T) {
+	cr := newCountingReader()
+	r := NewRefresher(cr, testRefreshPeriod)
+	defer r.Close()
+
+	require.NoError(t, r.ReadTag(testTagName, new(int)))
+	err := r.SetPeriod(testTagName, -time.Second)
+	assert.True(t, errors.Is(err, ErrBadRequest), "Wrong error: %v", err)
+	cr.waitForReads(t, testTagName, 3) // Still refreshing with the old period
+}
+
+func TestRefresherSetPeriodWaitsForRefresh(t *testing.T) {
+	brw := newBlockingReadWriter()
+	r := NewRefresher(brw, time.Hour)
+	defer r.Close()
+
+	require.NoError(t, r.SetPeriod(testTagName, testRefreshPeriod))
+	r.SubscribeChannel(testTagName, new(int), make(chan Change, 10))
+	brw.waitForStart(t)
+
+	// While the refresh is in progress, the new period doesn't start a second one
+	require.NoError(t, r.SetPeriod(testTagName, 2*testRefreshPeriod))
+	time.Sleep(10 * testRefreshPeriod)
+	assert.Len(t, brw.started, 0, "Refreshes should not overlap")
+
+	close(brw.release)
+	brw.waitForStart(t)
+}
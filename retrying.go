@@ -0,0 +1,160 @@
+package plc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Retrying wraps another ReadWriter and retries operations which fail with a transient error.
+// By default, reads are retried but writes are not, since a write may not be idempotent.
+type Retrying struct {
+	plc         ReadWriter
+	attempts    int
+	initial     time.Duration
+	max         time.Duration
+	jitter      float64
+	retryable   func(error) bool
+	retryWrites bool
+
+	randMutex sync.Mutex // rand.Rand is not safe for concurrent use
+	rand      *rand.Rand
+}
+
+var _ = ReadWriter(&Retrying{})        // Compiler makes sure this type is a ReadWriter
+var _ = ContextReadWriter(&Retrying{}) // Compiler makes sure this type is a ContextReadWriter
+
+// RetryingOption configures a Retrying.
+type RetryingOption interface {
+	apply(*Retrying)
+}
+
+// retryingOptionFunc wraps a func so it satisfies the RetryingOption interface.
+type retryingOptionFunc func(*Retrying)
+
+func (f retryingOptionFunc) apply(r *Retrying) { f(r) }
+
+// RetryAttempts sets the maximum number of attempts, including the first. The default is 3.
+func RetryAttempts(attempts int) RetryingOption {
+	return retryingOptionFunc(func(r *Retrying) {
+		r.attempts = attempts
+	})
+}
+
+// RetryBackoff sets the delay before the first retry, which doubles after each attempt up to max.
+// The defaults are 10ms and 1s.
+func RetryBackoff(initial, max time.Duration) RetryingOption {
+	return retryingOptionFunc(func(r *Retrying) {
+		r.initial, r.max = initial, max
+	})
+}
+
+// RetryJitter sets the fraction of each delay that is randomized, so many clients don't retry
+// in lockstep. For example, 0.5 results in a delay between 50% and 100% of the backoff.
+// The default is 0.2. The seed is used so tests can be repeatable.
+func RetryJitter(fraction float64, seed int64) RetryingOption {
+	return retryingOptionFunc(func(r *Retrying) {
+		r.jitter = fraction
+		r.rand = rand.New(rand.NewSource(seed))
+	})
+}
+
+// RetryClassifier sets the function which decides whether an error should be retried.
+// The default is IsRetryable.
+func RetryClassifier(retryable func(error) bool) RetryingOption {
+	return retryingOptionFunc(func(r *Retrying) {
+		r.retryable = retryable
+	})
+}
+
+// RetryWrites enables retrying writes. Only use this if writing the same value twice is safe.
+func RetryWrites() RetryingOption {
+	return retryingOptionFunc(func(r *Retrying) {
+		r.retryWrites = true
+	})
+}
+
+// IsRetryable returns true for errors which are likely to be transient. That includes
+// ErrPlcConnection, but never ErrBadRequest, since a bad request will fail every time.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrPlcConnection) && !errors.Is(err, ErrBadRequest)
+}
+
+// NewRetrying returns a Retrying which passes operations through to plc.
+func NewRetrying(plc ReadWriter, opts ...RetryingOption) *Retrying {
+	r := &Retrying{
+		plc:       plc,
+		attempts:  3,
+		initial:   10 * time.Millisecond,
+		max:       time.Second,
+		jitter:    0.2,
+		retryable: IsRetryable,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range opts {
+		opt.apply(r)
+	}
+	return r
+}
+
+func (r *Retrying) ReadTag(name string, value interface{}) error {
+	return r.ReadTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext reads the tag, retrying if the error is retryable.
+// If ctx is done while waiting to retry, ctx.Err() is returned.
+func (r *Retrying) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	return r.retry(ctx, func() error {
+		return NewContextReader(r.plc).ReadTagContext(ctx, name, value)
+	})
+}
+
+func (r *Retrying) WriteTag(name string, value interface{}) error {
+	return r.WriteTagContext(context.Background(), name, value)
+}
+
+// WriteTagContext writes the tag. It is only retried if RetryWrites was provided.
+func (r *Retrying) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	write := func() error {
+		return NewContextWriter(r.plc).WriteTagContext(ctx, name, value)
+	}
+	if !r.retryWrites {
+		return write()
+	}
+	return r.retry(ctx, write)
+}
+
+// retry calls op until it succeeds, returns an error which isn't retryable, or runs out of attempts.
+// The last error is returned.
+func (r *Retrying) retry(ctx context.Context, op func() error) error {
+	backoff := r.initial
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= r.attempts || !r.retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(r.withJitter(backoff))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > r.max {
+			backoff = r.max
+		}
+	}
+}
+
+// withJitter randomly reduces the delay by up to the jitter fraction.
+func (r *Retrying) withJitter(delay time.Duration) time.Duration {
+	r.randMutex.Lock()
+	random := r.rand.Float64()
+	r.randMutex.Unlock()
+	return delay - time.Duration(float64(delay)*r.jitter*random)
+}
@@ -0,0 +1,118 @@
+package plc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingReadWriter fails the first `failures` operations with err, then passes through.
+type failingReadWriter struct {
+	FakeReadWriter
+	failures int
+	err      error
+	calls    int
+}
+
+func (rw *failingReadWriter) ReadTag(name string, value interface{}) error {
+	rw.calls++
+	if rw.calls <= rw.failures {
+		return rw.err
+	}
+	return rw.FakeReadWriter.ReadTag(name, value)
+}
+
+func (rw *failingReadWriter) WriteTag(name string, value interface{}) error {
+	rw.calls++
+	if rw.calls <= rw.failures {
+		return rw.err
+	}
+	return rw.FakeReadWriter.WriteTag(name, value)
+}
+
+var errTestTransient = fmt.Errorf("timeout: %w", ErrPlcConnection)
+
+func newFastRetrying(rw ReadWriter, opts ...RetryingOption) *Retrying {
+	opts = append([]RetryingOption{RetryBackoff(time.Microsecond, time.Millisecond)}, opts...)
+	return NewRetrying(rw, opts...)
+}
+
+func TestRetryingReadSucceedsAfterTransientErrors(t *testing.T) {
+	rw := &failingReadWriter{FakeReadWriter: FakeReadWriter{testTagName: 7}, failures: 2, err: errTestTransient}
+	r := newFastRetrying(rw)
+
+	var actual int
+	assert.NoError(t, r.ReadTag(testTagName, &actual))
+	assert.Equal(t, 7, actual)
+	assert.Equal(t, 3, rw.calls)
+}
+
+func TestRetryingReadGivesUp(t *testing.T) {
+	rw := &failingReadWriter{FakeReadWriter: FakeReadWriter{testTagName: 7}, failures: 10, err: errTestTransient}
+	r := newFastRetrying(rw, RetryAttempts(4))
+
+	var actual int
+	err := r.ReadTag(testTagName, &actual)
+	assert.True(t, errors.Is(err, ErrPlcConnection))
+	assert.Equal(t, 4, rw.calls)
+}
+
+func TestRetryingDoesNotRetryBadRequest(t *testing.T) {
+	errs := map[string]error{
+		"BadRequest": ErrBadRequest,
+		"Both":       fmt.Errorf("%w: %v", ErrBadRequest, ErrPlcConnection),
+		"Other":      errors.New("Something else"),
+	}
+	for name, err := range errs {
+		t.Run(name, func(tt *testing.T) {
+			rw := &failingReadWriter{FakeReadWriter: FakeReadWriter{testTagName: 7}, failures: 1, err: err}
+			var actual int
+			assert.Equal(tt, err, newFastRetrying(rw).ReadTag(testTagName, &actual))
+			assert.Equal(tt, 1, rw.calls)
+		})
+	}
+}
+
+func TestRetryingCustomClassifier(t *testing.T) {
+	rw := &failingReadWriter{FakeReadWriter: FakeReadWriter{testTagName: 7}, failures: 1, err: ErrPlcInternal}
+	r := newFastRetrying(rw, RetryClassifier(func(err error) bool { return errors.Is(err, ErrPlcInternal) }))
+
+	var actual int
+	assert.NoError(t, r.ReadTag(testTagName, &actual))
+	assert.Equal(t, 2, rw.calls)
+}
+
+func TestRetryingWritesAreOptIn(t *testing.T) {
+	rw := &failingReadWriter{FakeReadWriter: FakeReadWriter{}, failures: 1, err: errTestTransient}
+	assert.Equal(t, errTestTransient, newFastRetrying(rw).WriteTag(testTagName, 8))
+	assert.Equal(t, 1, rw.calls)
+
+	rw = &failingReadWriter{FakeReadWriter: FakeReadWriter{}, failures: 1, err: errTestTransient}
+	assert.NoError(t, newFastRetrying(rw, RetryWrites()).WriteTag(testTagName, 8))
+	assert.Equal(t, 2, rw.calls)
+	assert.Equal(t, 8, rw.FakeReadWriter[testTagName])
+}
+
+func TestRetryingContextCancelledDuringBackoff(t *testing.T) {
+	rw := &failingReadWriter{FakeReadWriter: FakeReadWriter{testTagName: 7}, failures: 10, err: errTestTransient}
+	r := NewRetrying(rw, RetryBackoff(time.Hour, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var actual int
+	assert.Equal(t, context.DeadlineExceeded, r.ReadTagContext(ctx, testTagName, &actual))
+	assert.Equal(t, 1, rw.calls)
+}
+
+func TestRetryingJitter(t *testing.T) {
+	r := NewRetrying(FakeReadWriter{}, RetryJitter(0.5, 1))
+	for i := 0; i < 100; i++ {
+		delay := r.withJitter(time.Second)
+		assert.True(t, delay >= 500*time.Millisecond && delay <= time.Second, "Delay %v is out of range", delay)
+	}
+}
@@ -1,8 +1,10 @@
 package plc
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -14,33 +16,185 @@ const TagPrefix = "plctag"
 // It is important to note that ReadTag will attempt to read or write a slice or array up to its length.
 // This might cause a PLC error if the operation goes out of bounds.
 // It also means nothing will be read if a nil or empty slice is provided; this code cannot infer the length.
+// The names of components are built with TagPath. If the name being read can't be parsed, it is used as-is.
+//
+// Nested arrays or slices are normally read as nested PLC arrays, e.g. "TAG[1][2]". If the PLC tag is a
+// multi-dimensional array, they must instead be addressed as "TAG[1,2]". This is done for tags provided
+// with SplitTagDimensions, and for struct fields with a 'dims=N' option, e.g. `plctag:"Grid,dims=2"`.
+//
+// With SplitWholeArrays, arrays and slices of scalars are instead read with a single call.
 type SplitReader struct {
 	Reader
-	newAsyncer func(action) asyncer
+	newAsyncer  func(action) asyncer
+	dims        map[string]int
+	wholeArrays bool
 }
 
-var _ = Reader(SplitReader{}) // Compiler makes sure this type is a Reader
+var _ = Reader(SplitReader{})             // Compiler makes sure this type is a Reader
+var _ = ContextReader(SplitReader{})      // Compiler makes sure this type is a ContextReader
+var _ = RangeReader(SplitReader{})        // Compiler makes sure this type is a RangeReader
+var _ = ContextRangeReader(SplitReader{}) // Compiler makes sure this type is a ContextRangeReader
+
+// SplitOption configures a SplitReader or SplitWriter.
+type SplitOption interface {
+	apply(*splitConfig)
+}
+
+// splitOptionFunc wraps a func so it satisfies the SplitOption interface.
+type splitOptionFunc func(*splitConfig)
+
+func (f splitOptionFunc) apply(cfg *splitConfig) { f(cfg) }
+
+type splitConfig struct {
+	parallel        bool
+	concurrency     int
+	continueOnError bool
+	dims            map[string]int // Number of dimensions of multi-dimensional tags
+	wholeArrays     bool
+}
+
+// SplitContinueOnError causes the remaining components to be read or written after one fails.
+// All errors are returned as a MultiError, and components which were read successfully are
+// still populated. By default, the first error stops the operation.
+func SplitContinueOnError() SplitOption {
+	return splitOptionFunc(func(cfg *splitConfig) {
+		cfg.continueOnError = true
+	})
+}
+
+// SplitConcurrency sets the maximum number of concurrent operations for a parallel
+// SplitReader or SplitWriter. The default is 128. Smaller PLCs may need a much lower limit.
+// It has no effect on a serial SplitReader or SplitWriter.
+func SplitConcurrency(concurrency int) SplitOption {
+	return splitOptionFunc(func(cfg *splitConfig) {
+		cfg.concurrency = concurrency
+	})
+}
+
+// SplitTagDimensions provides the metadata of tags, as returned by libplctag's Device.GetAllTags.
+// When one of these tags has more than one dimension, nested arrays or slices are read from or
+// written to it with comma-separated indices, e.g. "TAG[1,2]".
+func SplitTagDimensions(tags []Tag) SplitOption {
+	return splitOptionFunc(func(cfg *splitConfig) {
+		for _, tag := range tags {
+			if len(tag.Dimensions) > 1 {
+				cfg.dims[tag.Name] = len(tag.Dimensions)
+			}
+		}
+	})
+}
+
+// SplitWholeArrays causes arrays and slices of scalars, such as []float32, to be read or written with
+// a single call to the underlying Reader or Writer instead of one call per element. It should only be
+// used if that Reader or Writer supports arrays, like libplctag's Device, which transfers the whole
+// array in one request. Arrays of structs and multi-dimensional arrays are still split.
+func SplitWholeArrays() SplitOption {
+	return splitOptionFunc(func(cfg *splitConfig) {
+		cfg.wholeArrays = true
+	})
+}
+
+// newSplitConfig returns the configuration with the options applied.
+func newSplitConfig(parallel bool, opts []SplitOption) splitConfig {
+	cfg := splitConfig{parallel: parallel, concurrency: defaultMaxRoutines, dims: map[string]int{}}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	return cfg
+}
+
+// asyncerFunc returns a function to create the asyncer for each operation.
+func (cfg splitConfig) asyncerFunc() func(action) asyncer {
+	newAsyncer := func(act action) asyncer { return newNotAsync(act) }
+	if cfg.parallel {
+		newAsyncer = func(act action) asyncer { return newAsyncLimited(act, cfg.concurrency) }
+	}
+	if cfg.continueOnError {
+		newInnerAsyncer := newAsyncer
+		newAsyncer = func(act action) asyncer { return newCollectingAsyncer(newInnerAsyncer, act) }
+	}
+	return newAsyncer
+}
 
 // NewSplitReader returns a SplitReader.
-func NewSplitReader(rd Reader) SplitReader {
-	return SplitReader{Reader: rd, newAsyncer: func(act action) asyncer { return newNotAsync(act) }}
+func NewSplitReader(rd Reader, opts ...SplitOption) SplitReader {
+	cfg := newSplitConfig(false, opts)
+	return SplitReader{Reader: rd, newAsyncer: cfg.asyncerFunc(), dims: cfg.dims, wholeArrays: cfg.wholeArrays}
 }
 
 // NewSplitReaderParallel returns a SplitReader which makes calls in parallel.
-func NewSplitReaderParallel(rd Reader) SplitReader {
-	return SplitReader{Reader: rd, newAsyncer: func(act action) asyncer { return newAsync(act) }}
+func NewSplitReaderParallel(rd Reader, opts ...SplitOption) SplitReader {
+	cfg := newSplitConfig(true, opts)
+	return SplitReader{Reader: rd, newAsyncer: cfg.asyncerFunc(), dims: cfg.dims, wholeArrays: cfg.wholeArrays}
 }
 
 func (rd SplitReader) ReadTag(name string, value interface{}) error {
-	as := rd.newAsyncer(rd.Reader.ReadTag)
-	rd.readTagAsync(name, value, as)
+	return rd.ReadTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext is the same as ReadTag, but the context is passed to every underlying read.
+func (rd SplitReader) ReadTagContext(ctx context.Context, name string, value interface{}) error {
+	crd := NewContextReader(rd.Reader)
+	as := rd.newAsyncer(func(name string, value interface{}) error {
+		return WrapOpError(crd.ReadTagContext(ctx, name, value), "SplitReader", "ReadTag", name)
+	})
+	rd.readTagAsync(splitPath(name), value, 0, as)
 	return as.Wait()
 }
 
-func (rd SplitReader) readTagAsync(name string, value interface{}, as asyncer) {
+func (rd SplitReader) ReadRange(name string, start int, value interface{}) error {
+	return rd.ReadRangeContext(context.Background(), name, start, value)
+}
+
+// ReadRangeContext reads consecutive elements of the named array, starting at index start, into
+// value, which must be a pointer to an array or slice. If the underlying Reader is a RangeReader and
+// the elements aren't split, the whole range is read with one call. Otherwise each element is
+// read separately, e.g. "TAG[100]", "TAG[101]", and so on.
+func (rd SplitReader) ReadRangeContext(ctx context.Context, name string, start int, value interface{}) error {
+	arr, err := RangeElements(name, value)
+	if err != nil {
+		return WrapOpError(err, "SplitReader", "ReadRange", name)
+	}
+
+	if readsRangeAtOnce(rd.Reader, arr) {
+		return WrapOpError(ReadRangeContext(ctx, rd.Reader, name, start, value), "SplitReader", "ReadRange", name)
+	}
+	return rd.readRangeElements(ctx, name, start, arr)
+}
+
+// readRangeElements reads each element of the range separately into arr.
+func (rd SplitReader) readRangeElements(ctx context.Context, name string, start int, arr reflect.Value) error {
+	crd := NewContextReader(rd.Reader)
+	as := rd.newAsyncer(func(name string, value interface{}) error {
+		return WrapOpError(crd.ReadTagContext(ctx, name, value), "SplitReader", "ReadTag", name)
+	})
+	path := splitPath(name)
+	for i := 0; i < arr.Len(); i++ {
+		rd.readValue(path.Child(IndexSegment(start+i)), arr.Index(i), 0, as)
+	}
+	return as.Wait()
+}
+
+// splitPath returns the TagPath for a name being split. The empty name is the empty path, so the
+// components of a struct are top-level tags. A name which can't be parsed is treated as a symbol.
+func splitPath(name string) TagPath {
+	if name == "" {
+		return nil
+	}
+	path, err := ParseTagPath(name)
+	if err != nil {
+		return TagPath{SymbolSegment(name)}
+	}
+	return path
+}
+
+// readTagAsync reads the value from the path. If the value is an array or slice, dims is the number of
+// dimensions of the PLC array, or 0 if it should be looked up with the path.
+func (rd SplitReader) readTagAsync(path TagPath, value interface{}, dims int, as asyncer) {
 	v := reflect.ValueOf(value)
 	if v.Kind() != reflect.Ptr {
-		as.AddError(ErrNonPointerRead{TagName: name, Kind: v.Kind()})
+		name := path.String()
+		as.AddError(WrapOpError(ErrNonPointerRead{TagName: name, Kind: v.Kind()}, "SplitReader", "ReadTag", name))
 		return
 	}
 
@@ -52,31 +206,38 @@ func (rd SplitReader) readTagAsync(name string, value interface{}, as asyncer) {
 				continue // Type is not exported, so skip it
 			}
 
-			// Generate the name of the struct's field and recurse
-			fieldName, ok := getNameOfField(str, i, false)
+			// Generate the path of the struct's field and recurse
+			fieldPath, fieldDims, ok := getPathOfField(str, i, false)
 			if !ok {
 				continue // Can't touch that
 			}
-			if name != "" {
-				fieldName = name + "." + fieldName // add prefix
-			}
 			field := str.Field(i)
-			rd.readValue(fieldName, field, as)
+			rd.readValue(path.Join(fieldPath), field, fieldDims, as)
 		}
 	case reflect.Array, reflect.Slice:
-		arr := v.Elem()
-		for idx := 0; idx < arr.Len(); idx++ {
-			rd.readValue(TagWithIndex(name, idx), arr.Index(idx), as)
+		if dims == 0 {
+			dims = rd.dims[path.String()]
+		}
+		if rd.wholeArrays && dims <= 1 && isScalarKind(v.Elem().Type().Elem().Kind()) {
+			as.Add(path.String(), value)
+			return
+		}
+		err := forEachElement(path, v.Elem(), dims, func(elemPath TagPath, elem reflect.Value) {
+			rd.readValue(elemPath, elem, 0, as)
+		})
+		if err != nil {
+			as.AddError(WrapOpError(err, "SplitReader", "ReadTag", path.String()))
 		}
 	default:
 		// Just try with the underlying type
-		as.Add(name, value)
+		as.Add(path.String(), value)
 	}
 }
 
-func (rd SplitReader) readValue(name string, val reflect.Value, as asyncer) {
+func (rd SplitReader) readValue(path TagPath, val reflect.Value, dims int, as asyncer) {
 	if !val.CanAddr() {
-		as.AddError(fmt.Errorf("Cannot address %s", name))
+		name := path.String()
+		as.AddError(WrapOpError(fmt.Errorf("%w: cannot address %s", ErrBadRequest, name), "SplitReader", "ReadTag", name))
 		return
 	}
 
@@ -91,22 +252,80 @@ func (rd SplitReader) readValue(name string, val reflect.Value, as asyncer) {
 		valPointer = val.Interface()
 	}
 
-	rd.readTagAsync(name, valPointer, as)
+	rd.readTagAsync(path, valPointer, dims, as)
+}
+
+// forEachElement calls f for each element of arr. If dims is more than 1, that many levels of nested
+// arrays or slices are combined into a single multi-dimensional index.
+// An error is returned if arr isn't nested deeply enough.
+func forEachElement(path TagPath, arr reflect.Value, dims int, f func(TagPath, reflect.Value)) error {
+	var recurse func(indices []int, arr reflect.Value) error
+	recurse = func(indices []int, arr reflect.Value) error {
+		for idx := 0; idx < arr.Len(); idx++ {
+			elemIndices := append(indices[:len(indices):len(indices)], idx)
+			elem := arr.Index(idx)
+			if len(elemIndices) >= dims {
+				f(path.Child(IndexSegment(elemIndices...)), elem)
+				continue
+			}
+
+			if elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+				elem = elem.Elem()
+			}
+			if elem.Kind() != reflect.Array && elem.Kind() != reflect.Slice {
+				return fmt.Errorf("%w: a %d-dimensional tag requires nested arrays or slices, not %v", ErrBadRequest, dims, arr.Type())
+			}
+			if err := recurse(elemIndices, elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return recurse(nil, arr)
 }
 
 // SplitWriter splits writes of structs and arrays into separate writes of their components.
+// Multi-dimensional arrays and SplitWholeArrays are handled in the same way as by SplitReader.
 type SplitWriter struct {
 	Writer
+	newAsyncer  func(action) asyncer
+	dims        map[string]int
+	wholeArrays bool
 }
 
-var _ = Writer(SplitWriter{}) // Compiler makes sure this type is a Writer
+var _ = Writer(SplitWriter{})        // Compiler makes sure this type is a Writer
+var _ = ContextWriter(SplitWriter{}) // Compiler makes sure this type is a ContextWriter
 
 // NewSplitWriter returns a SplitWriter.
-func NewSplitWriter(wr Writer) SplitWriter {
-	return SplitWriter{wr}
+func NewSplitWriter(wr Writer, opts ...SplitOption) SplitWriter {
+	cfg := newSplitConfig(false, opts)
+	return SplitWriter{Writer: wr, newAsyncer: cfg.asyncerFunc(), dims: cfg.dims, wholeArrays: cfg.wholeArrays}
+}
+
+// NewSplitWriterParallel returns a SplitWriter which makes calls in parallel.
+// Since the writes are parallel, the order in which they're applied is not defined.
+func NewSplitWriterParallel(wr Writer, opts ...SplitOption) SplitWriter {
+	cfg := newSplitConfig(true, opts)
+	return SplitWriter{Writer: wr, newAsyncer: cfg.asyncerFunc(), dims: cfg.dims, wholeArrays: cfg.wholeArrays}
 }
 
 func (sw SplitWriter) WriteTag(name string, value interface{}) error {
+	return sw.WriteTagContext(context.Background(), name, value)
+}
+
+// WriteTagContext is the same as WriteTag, but the context is passed to every underlying write.
+func (sw SplitWriter) WriteTagContext(ctx context.Context, name string, value interface{}) error {
+	cwr := NewContextWriter(sw.Writer)
+	as := sw.newAsyncer(func(name string, value interface{}) error {
+		return WrapOpError(cwr.WriteTagContext(ctx, name, value), "SplitWriter", "WriteTag", name)
+	})
+	sw.writeTagAsync(splitPath(name), value, 0, as)
+	return as.Wait()
+}
+
+// writeTagAsync writes the value to the path. If the value is an array or slice, dims is the number of
+// dimensions of the PLC array, or 0 if it should be looked up with the path.
+func (sw SplitWriter) writeTagAsync(path TagPath, value interface{}, dims int, as asyncer) {
 	v := reflect.ValueOf(value)
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem() // Naturally use what the pointer is pointing to (but only do so once)
@@ -120,68 +339,79 @@ func (sw SplitWriter) WriteTag(name string, value interface{}) error {
 				continue // Type is not exported, so skip it
 			}
 
-			// Generate the name of the struct's field and recurse
-			fieldName, ok := getNameOfField(str, i, true)
+			// Generate the path of the struct's field and recurse
+			fieldPath, fieldDims, ok := getPathOfField(str, i, true)
 			if !ok {
 				continue // Can't touch that
 			}
-			if name != "" {
-				fieldName = name + "." + fieldName // add prefix
-			}
 			fieldPointer := str.Field(i).Interface()
-
-			if err := sw.WriteTag(fieldName, fieldPointer); err != nil {
-				return err
-			}
+			sw.writeTagAsync(path.Join(fieldPath), fieldPointer, fieldDims, as)
 		}
 	case reflect.Array, reflect.Slice:
-		arr := v
-		for idx := 0; idx < arr.Len(); idx++ {
-			itemName := TagWithIndex(name, idx)
-			itemPointer := arr.Index(idx).Interface()
-			if err := sw.WriteTag(itemName, itemPointer); err != nil {
-				return err
-			}
+		if dims == 0 {
+			dims = sw.dims[path.String()]
+		}
+		if sw.wholeArrays && dims <= 1 && isScalarKind(v.Type().Elem().Kind()) {
+			as.Add(path.String(), v.Interface())
+			return
+		}
+		err := forEachElement(path, v, dims, func(elemPath TagPath, elem reflect.Value) {
+			sw.writeTagAsync(elemPath, elem.Interface(), 0, as)
+		})
+		if err != nil {
+			as.AddError(WrapOpError(err, "SplitWriter", "WriteTag", path.String()))
 		}
+	case reflect.Invalid:
+		// A nil value or nil pointer has nothing to write
+		as.AddError(WrapOpError(fmt.Errorf("%w: cannot write a nil value", ErrBadRequest), "SplitWriter", "WriteTag", path.String()))
 	default:
 		// Just try with the underlying type
-		return sw.Writer.WriteTag(name, v.Interface())
+		as.Add(path.String(), v.Interface())
 	}
-
-	return nil
 }
 
-// getNameOfField gets the name of field i in the provided struct str.
+// getPathOfField gets the path of field i in the provided struct str, relative to the struct.
 // The second return argument indicates whether it's ok to use the field. If false,
 // the field should be skipped.
-// It does not consider any struct fields other than 'omitempty', which indicates
-// the field should be skipped if it's a zero value. This is only relevant if
-// allowOmitEmpty is true.
-func getNameOfField(str reflect.Value, i int, allowOmitEmpty bool) (string, bool) {
+// The second return value is the number of dimensions of the PLC array, or 0 if it isn't known.
+// It considers three struct tag options. 'bit=N' addresses bit N of the named integer, which
+// should be read into or written from a bool. 'dims=N' indicates the field is an N-dimensional
+// PLC array. 'omitempty' indicates the field should be skipped if it's a zero value, which is
+// only relevant if allowOmitEmpty is true.
+func getPathOfField(str reflect.Value, i int, allowOmitEmpty bool) (TagPath, int, bool) {
 	field := str.Type().Field(i)
 	plctag := field.Tag.Get(TagPrefix)
 	if plctag == "" {
-		return field.Name, true
+		return TagPath{SymbolSegment(field.Name)}, 0, true
 	}
 	opts := strings.Split(plctag, ",")
 	name := opts[0]
 	switch name {
 	case "-":
-		return "", false // Ignore this field
+		return nil, 0, false // Ignore this field
 	case "":
 		name = field.Name // Use the field name as the name
 	}
 
-	if !allowOmitEmpty {
-		return name, true
-	}
-
+	ok := true
+	dims := 0
 	for _, opt := range opts[1:] {
-		if opt == "omitempty" {
-			return name, !str.Field(i).IsZero()
+		switch {
+		case strings.HasPrefix(opt, "bit="):
+			// If the bit isn't a number, it's left in the name so the PLC will reject it
+			name += "." + strings.TrimPrefix(opt, "bit=")
+		case strings.HasPrefix(opt, "dims="):
+			dims, _ = strconv.Atoi(strings.TrimPrefix(opt, "dims=")) // An invalid number is ignored
+		case opt == "omitempty" && allowOmitEmpty:
+			ok = !str.Field(i).IsZero()
 		}
 		// else an unused option was included, which is odd but not an error
 	}
 
-	return name, true
+	// The name may include several segments, e.g. "Status.3"
+	path, err := ParseTagPath(name)
+	if err != nil {
+		return TagPath{SymbolSegment(name)}, dims, ok
+	}
+	return path, dims, ok
 }
@@ -1,9 +1,11 @@
 package plc
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -547,3 +549,239 @@ func TestSplitWriteArrayOfStruct(t *testing.T) {
 	assert.Equal(t, expected[1].I, fakeRW[testTagName+"[1].I"])
 	assert.Equal(t, expected[1].MY_FLOAT, fakeRW[testTagName+"[1].MY_FLOAT"])
 }
+
+type multiErrorTestStruct struct {
+	A, B, C, D int
+}
+
+func TestSplitReaderContinueOnError(t *testing.T) {
+	constructors := map[string]func(Reader, ...SplitOption) SplitReader{
+		"Serial":   NewSplitReader,
+		"Parallel": NewSplitReaderParallel,
+	}
+	for name, newSplitReader := range constructors {
+		t.Run(name, func(tt *testing.T) {
+			fakeRW := FakeReadWriter{testTagName + ".B": 2, testTagName + ".D": 4}
+			sr := newSplitReader(fakeRW, SplitContinueOnError())
+
+			var actual multiErrorTestStruct
+			err := sr.ReadTag(testTagName, &actual)
+			require.Error(tt, err)
+
+			multi, ok := err.(MultiError)
+			require.True(tt, ok, "Error should be a MultiError, but got %T", err)
+			assert.Equal(tt, []string{testTagName + ".A", testTagName + ".C"}, multi.TagNames())
+			assert.Equal(tt, multiErrorTestStruct{B: 2, D: 4}, actual, "Successful reads should be populated")
+
+			var opErr OpError
+			assert.True(tt, errors.As(err, &opErr))
+		})
+	}
+}
+
+func TestSplitReaderStopsOnErrorByDefault(t *testing.T) {
+	fakeRW := FakeReadWriter{testTagName + ".B": 2, testTagName + ".D": 4}
+
+	var actual multiErrorTestStruct
+	err := NewSplitReader(fakeRW).ReadTag(testTagName, &actual)
+	require.Error(t, err)
+	_, isMulti := err.(MultiError)
+	assert.False(t, isMulti)
+	assert.Equal(t, multiErrorTestStruct{}, actual, "Reading should stop at the first error")
+}
+
+// failingWriter fails writes to tags in fail, and records the others.
+type failingWriter struct {
+	FakeReadWriter
+	fail map[string]bool
+}
+
+func (fw failingWriter) WriteTag(name string, value interface{}) error {
+	if fw.fail[name] {
+		return fmt.Errorf("%w: can't write %s", ErrBadRequest, name)
+	}
+	return fw.FakeReadWriter.WriteTag(name, value)
+}
+
+func TestSplitWriterContinueOnError(t *testing.T) {
+	fw := failingWriter{
+		FakeReadWriter: FakeReadWriter{},
+		fail:           map[string]bool{testTagName + ".A": true, testTagName + ".D": true},
+	}
+	err := NewSplitWriter(fw, SplitContinueOnError()).WriteTag(testTagName, multiErrorTestStruct{1, 2, 3, 4})
+
+	multi, ok := err.(MultiError)
+	require.True(t, ok, "Error should be a MultiError, but got %T", err)
+	assert.Equal(t, []string{testTagName + ".A", testTagName + ".D"}, multi.TagNames())
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	assert.Equal(t, FakeReadWriter{testTagName + ".B": 2, testTagName + ".C": 3}, fw.FakeReadWriter)
+}
+
+func TestSplitReaderParallelConcurrency(t *testing.T) {
+	const limit = 2
+	running := make(chan struct{}, 10)
+	release := make(chan struct{})
+
+	sr := NewSplitReaderParallel(readerFunc(func(name string, value interface{}) error {
+		running <- struct{}{}
+		<-release
+		return nil
+	}), SplitConcurrency(limit))
+
+	result := make(chan error)
+	go func() {
+		data := make([]int, 8)
+		result <- sr.ReadTag(testTagName, &data)
+	}()
+
+	for i := 0; i < limit; i++ {
+		<-running
+	}
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 0, len(running), "No more than the limit should run at once")
+
+	close(release)
+	assert.NoError(t, receiveError(t, result))
+	assert.Equal(t, 8-limit, len(running), "The rest should run once workers are free")
+}
+
+func TestSplitWriterParallel(t *testing.T) {
+	fakeRW := FakeReadWriter{}
+	var mutex sync.Mutex
+	sw := NewSplitWriterParallel(writerFunc(func(name string, value interface{}) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return fakeRW.WriteTag(name, value)
+	}))
+
+	err := sw.WriteTag(testTagName, []int{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, FakeReadWriter{
+		testTagName + "[0]": 1,
+		testTagName + "[1]": 2,
+		testTagName + "[2]": 3,
+	}, fakeRW)
+}
+
+func TestSplitWriterParallelIsParallel(t *testing.T) {
+	const numToTest = 8
+	started := make(chan struct{}, numToTest)
+	release := make(chan struct{})
+
+	sw := NewSplitWriterParallel(writerFunc(func(name string, value interface{}) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}), SplitConcurrency(numToTest))
+
+	result := make(chan error)
+	go func() { result <- sw.WriteTag(testTagName, make([]int, numToTest)) }()
+
+	for i := 0; i < numToTest; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			require.FailNow(t, "Writes did not all start in parallel")
+		}
+	}
+	close(release)
+	assert.NoError(t, receiveError(t, result))
+}
+
+func TestSplitWriterParallelContinueOnError(t *testing.T) {
+	sw := NewSplitWriterParallel(writerFunc(func(name string, value interface{}) error {
+		if value.(int)%2 == 1 {
+			return fmt.Errorf("%w: odd", ErrBadRequest)
+		}
+		return nil
+	}), SplitContinueOnError(), SplitConcurrency(2))
+
+	err := sw.WriteTag(testTagName, []int{0, 1, 2, 3, 4, 5})
+	multi, ok := err.(MultiError)
+	require.True(t, ok, "Error should be a MultiError, but got %T", err)
+	assert.Equal(t, []string{testTagName + "[1]", testTagName + "[3]", testTagName + "[5]"}, multi.TagNames())
+}
+
+type multiDimTestStruct struct {
+	Grid   [2][2]int16 `plctag:",dims=2"`
+	Nested [2][1]int16
+}
+
+func TestSplitReaderMultiDimensional(t *testing.T) {
+	fakeRW := FakeReadWriter{
+		"GRID[0,0]": 1, "GRID[0,1]": 2, "GRID[0,2]": 3,
+		"GRID[1,0]": 4, "GRID[1,1]": 5, "GRID[1,2]": 6,
+	}
+	sr := NewSplitReader(fakeRW, SplitTagDimensions([]Tag{
+		{Name: "GRID", Dimensions: []int{2, 3}},
+		{Name: "LIST", Dimensions: []int{3}},
+	}))
+
+	var actual [2][3]int
+	require.NoError(t, sr.ReadTag("GRID", &actual))
+	assert.Equal(t, [2][3]int{{1, 2, 3}, {4, 5, 6}}, actual)
+
+	var slices = [][]int{make([]int, 3), make([]int, 3)}
+	require.NoError(t, sr.ReadTag("GRID", &slices))
+	assert.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}}, slices)
+
+	var flat [2]int
+	err := sr.ReadTag("GRID", &flat)
+	assert.True(t, errors.Is(err, ErrBadRequest), "A flat array can't be read from a 2-D tag: %v", err)
+}
+
+func TestSplitReaderMultiDimensionalStructTag(t *testing.T) {
+	fakeRW := FakeReadWriter{
+		testTagName + ".Grid[0,0]": int16(1), testTagName + ".Grid[0,1]": int16(2),
+		testTagName + ".Grid[1,0]": int16(3), testTagName + ".Grid[1,1]": int16(4),
+		testTagName + ".Nested[0][0]": int16(5), testTagName + ".Nested[1][0]": int16(6),
+	}
+
+	var actual multiDimTestStruct
+	require.NoError(t, NewSplitReader(fakeRW).ReadTag(testTagName, &actual))
+	assert.Equal(t, multiDimTestStruct{Grid: [2][2]int16{{1, 2}, {3, 4}}, Nested: [2][1]int16{{5}, {6}}}, actual)
+}
+
+func TestSplitWriterMultiDimensional(t *testing.T) {
+	fakeRW := FakeReadWriter{}
+	sw := NewSplitWriter(fakeRW, SplitTagDimensions([]Tag{{Name: "GRID", Dimensions: []int{2, 2}}}))
+
+	require.NoError(t, sw.WriteTag("GRID", [][]int{{1, 2}, {3, 4}}))
+	assert.Equal(t, FakeReadWriter{"GRID[0,0]": 1, "GRID[0,1]": 2, "GRID[1,0]": 3, "GRID[1,1]": 4}, fakeRW)
+
+	fakeRW = FakeReadWriter{}
+	require.NoError(t, NewSplitWriter(fakeRW).WriteTag(testTagName, multiDimTestStruct{Grid: [2][2]int16{{1, 2}, {3, 4}}}))
+	assert.Equal(t, int16(4), fakeRW[testTagName+".Grid[1,1]"])
+	assert.Equal(t, int16(0), fakeRW[testTagName+".Nested[1][0]"])
+}
+
+func TestSplitReaderWholeArrays(t *testing.T) {
+	fakeRW := FakeReadWriter{
+		"ARR":       []float32{1, 2, 3},
+		"ROWS[0]":   [2]int16{4, 5},
+		"ROWS[1]":   [2]int16{6, 7},
+		"GRID[0,0]": 9,
+	}
+	sr := NewSplitReader(fakeRW, SplitWholeArrays(), SplitTagDimensions([]Tag{{Name: "GRID", Dimensions: []int{1, 1}}}))
+
+	actual := make([]float32, 3)
+	require.NoError(t, sr.ReadTag("ARR", &actual))
+	assert.Equal(t, []float32{1, 2, 3}, actual)
+
+	var rows [2][2]int16
+	require.NoError(t, sr.ReadTag("ROWS", &rows), "Each row should be read whole")
+	assert.Equal(t, [2][2]int16{{4, 5}, {6, 7}}, rows)
+
+	var grid [1][1]int
+	require.NoError(t, sr.ReadTag("GRID", &grid), "A multi-dimensional array should still be split")
+	assert.Equal(t, [1][1]int{{9}}, grid)
+}
+
+func TestSplitWriterWholeArrays(t *testing.T) {
+	fakeRW := FakeReadWriter{}
+	sw := NewSplitWriter(fakeRW, SplitWholeArrays())
+
+	require.NoError(t, sw.WriteTag("ARR", []float32{1, 2}))
+	require.NoError(t, sw.WriteTag("STRUCTS", []struct{ A int }{{3}}))
+	assert.Equal(t, FakeReadWriter{"ARR": []float32{1, 2}, "STRUCTS[0].A": 3}, fakeRW)
+}
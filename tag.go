@@ -8,11 +8,41 @@ import (
 	"unicode"
 )
 
+// NoBit is returned by ParseQualifiedTagNameBit when a tag name has no bit segment.
+const NoBit = -1
+
+// maxBit is the highest bit number which can be addressed, which is the last bit of a LINT.
+const maxBit = 63
+
 // TagWithIndex provides the fully qualified tag for the given index of an array.
 func TagWithIndex(name string, index int) string {
 	// Array tags can be read by adding the index to the string, e.g. "EXAMPLE[0]"
 	// Perhaps this should have error checking on index<0.
-	return fmt.Sprintf("%s[%d]", name, index)
+	path, err := ParseTagPath(name)
+	if err != nil {
+		return fmt.Sprintf("%s[%d]", name, index) // Leave it to the PLC to reject the name
+	}
+	return path.Child(IndexSegment(index)).String()
+}
+
+// TagWithIndices provides the fully qualified tag for the given element of a multi-dimensional
+// array, e.g. "EXAMPLE[1,2]". Provide one index per dimension.
+func TagWithIndices(name string, indices ...int) string {
+	path, err := ParseTagPath(name)
+	if err != nil {
+		return name + IndexSegment(indices...).String() // Leave it to the PLC to reject the name
+	}
+	return path.Child(IndexSegment(indices...)).String()
+}
+
+// TagWithBit provides the fully qualified tag for the given bit of an integer tag.
+func TagWithBit(name string, bit int) string {
+	// Bits can be addressed by adding the bit number as a field, e.g. "STATUS.3"
+	path, err := ParseTagPath(name)
+	if err != nil {
+		return fmt.Sprintf("%s.%d", name, bit) // Leave it to the PLC to reject the name
+	}
+	return path.Child(BitSegment(bit)).String()
 }
 
 type Tag struct {
@@ -51,7 +81,35 @@ func (tag Tag) ElemCount() int {
 // array index) and splits them into their respresentative
 // parts.
 //
-// From libplctag (we are ignoring bit_seg)
+// A trailing bit segment (e.g. "Status.3") is rejected, since the
+// components couldn't tell the bit apart from the integer containing it.
+// Use ParseQualifiedTagNameBit or ParseTagPath for names which may have a bit.
+func ParseQualifiedTagName(qtn string) ([]string, error) {
+	components, bit, err := ParseQualifiedTagNameBit(qtn)
+	if err != nil {
+		return nil, err
+	}
+	if bit != NoBit {
+		return nil, fmt.Errorf("unexpected bit segment '.%d'; use ParseQualifiedTagNameBit for bits", bit)
+	}
+	return components, nil
+}
+
+// ParseQualifiedTagNameBit is the same as ParseQualifiedTagName,
+// but it also returns the bit number of a trailing bit segment
+// (e.g. 3 for "Status.3"), or NoBit if there isn't one.
+func ParseQualifiedTagNameBit(qtn string) ([]string, int, error) {
+	path, err := ParseTagPath(qtn)
+	if err != nil {
+		return nil, NoBit, err
+	}
+	return path.components(), path.Bit(), nil
+}
+
+// ParseTagPath parses a tag name such as "Program:Main.Motors[2,3].Status.4"
+// into its segments.
+//
+// From libplctag
 /*
  * The EBNF is:
  *
@@ -69,8 +127,8 @@ func (tag Tag) ElemCount() int {
  * NUMERIC_SEG ::= [0-9]+
  *
  */
-func ParseQualifiedTagName(qtn string) ([]string, error) {
-	var ret []string
+func ParseTagPath(qtn string) (TagPath, error) {
+	var ret TagPath
 	i := 0
 
 	alpha := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -101,17 +159,17 @@ func ParseQualifiedTagName(qtn string) ([]string, error) {
 				break
 			}
 		}
-		ret = append(ret, qtn[begin:i])
+		ret = append(ret, SymbolSegment(qtn[begin:i]))
 		return nil
 	}
 
 	/* NUMERIC_SEG := [:space:]* [0-9]+ [:space:]* */
-	parseNumericSegment := func() error {
+	parseNumericSegment := func() (int, error) {
 		var begin, end int
 
 		/* [:space:]* */
 		if i >= len(qtn) {
-			return fmt.Errorf("expected number")
+			return 0, fmt.Errorf("expected number")
 		}
 		for ; i < len(qtn); i++ {
 			if !unicode.IsSpace(rune(qtn[i])) {
@@ -124,10 +182,10 @@ func ParseQualifiedTagName(qtn string) ([]string, error) {
 
 		/* [0-9]  */
 		if i >= len(qtn) {
-			return fmt.Errorf("expected number")
+			return 0, fmt.Errorf("expected number")
 		}
 		if !bytes.ContainsAny([]byte{qtn[i]}, num) {
-			return fmt.Errorf("Expected digit, got '%c'", qtn[i])
+			return 0, fmt.Errorf("Expected digit, got '%c'", qtn[i])
 		}
 		i++
 		/* [0-9]* */
@@ -150,26 +208,47 @@ func ParseQualifiedTagName(qtn string) ([]string, error) {
 
 		asUint64, err := strconv.ParseUint(qtn[begin:end], 10, 32)
 		if err != nil {
-			return fmt.Errorf("Invalid array index '%s'", qtn[begin:end])
+			return 0, fmt.Errorf("Invalid array index '%s'", qtn[begin:end])
 		}
-		ret = append(ret, fmt.Sprintf("%d", asUint64))
-		return nil
+		return int(asUint64), nil
 	}
 
 	/* array_seg ::= numeric_seg ( ',' numeric_seg )* */
 	parseArraySegment := func() error {
-		if err := parseNumericSegment(); err != nil {
+		index, err := parseNumericSegment()
+		if err != nil {
 			return err
 		}
-		for i < len(qtn) {
-			if qtn[i] != ',' {
-				return nil
-			}
+		indices := []int{index}
+		for i < len(qtn) && qtn[i] == ',' {
 			i++
-			if err := parseNumericSegment(); err != nil {
+			index, err := parseNumericSegment()
+			if err != nil {
 				return err
 			}
+			indices = append(indices, index)
+		}
+		ret = append(ret, IndexSegment(indices...))
+		return nil
+	}
+
+	/* bit_seg ::= '.' [0-9]+ */
+	parseBitSegment := func() error {
+		begin := i
+		for ; i < len(qtn); i++ {
+			if !bytes.ContainsAny([]byte{qtn[i]}, num) {
+				break
+			}
 		}
+		if i < len(qtn) {
+			return fmt.Errorf("bit segment must be last; got '%c'", qtn[i])
+		}
+
+		asInt, err := strconv.Atoi(qtn[begin:i])
+		if err != nil || asInt > maxBit {
+			return fmt.Errorf("Invalid bit number '%s'", qtn[begin:i])
+		}
+		ret = append(ret, BitSegment(asInt))
 		return nil
 	}
 
@@ -181,6 +260,9 @@ func ParseQualifiedTagName(qtn string) ([]string, error) {
 		switch qtn[i] {
 		case '.':
 			i++
+			if i < len(qtn) && bytes.ContainsAny([]byte{qtn[i]}, num) {
+				return parseBitSegment()
+			}
 			return parseSymbolicSegment()
 		case '[':
 			i++
@@ -200,8 +282,8 @@ func ParseQualifiedTagName(qtn string) ([]string, error) {
 		return nil
 	}
 
-	/* If the tag begins with "Program:", drop that prefix; we will append it
-	 * to the starting symbolic segment before returning.  */
+	/* If the tag begins with "Program:", drop that prefix; the starting
+	 * symbolic segment is the program's name.  */
 	var hadProgramPrefix bool
 	if strings.HasPrefix(qtn, "Program:") {
 		hadProgramPrefix = true
@@ -220,7 +302,7 @@ func ParseQualifiedTagName(qtn string) ([]string, error) {
 		}
 	}
 
-	/* tag ::= SYMBOLIC_SEG ( tag_seg )* */
+	/* tag ::= SYMBOLIC_SEG ( tag_seg )* ( bit_seg )? */
 	if err := parseSymbolicSegment(); err != nil {
 		return nil, err
 	}
@@ -231,7 +313,7 @@ func ParseQualifiedTagName(qtn string) ([]string, error) {
 	}
 
 	if hadProgramPrefix {
-		ret[0] = "Program:" + ret[0]
+		ret[0] = ProgramSegment(ret[0].Name)
 	}
 
 	return ret, nil
@@ -38,6 +38,15 @@ var parserTests = []struct {
 	{"ARRAY[ 0 ,  1  , 2 ]", []string{"ARRAY", "0", "1", "2"}, ""},
 	{"Field.Array[42].Member[16]", []string{"Field", "Array", "42", "Member", "16"}, ""},
 
+	// A trailing bit segment must be parsed by ParseQualifiedTagNameBit, so it isn't confused with the integer.
+	{"Status.3", nil, "unexpected bit segment '.3'"},
+	{"Field.Array[42].Status.31", nil, "unexpected bit segment '.31'"},
+	{"Status.3.Member", nil, "bit segment must be last; got '.'"},
+	{"Status.3[0]", nil, "bit segment must be last; got '['"},
+	{"Status.3x", nil, "bit segment must be last; got 'x'"},
+	{"Status.64", nil, "Invalid bit number '64'"},
+	{"3", nil, "begins with a non-alphabetic character '3'"},
+
 	// Special case: "Program:" is a valid prefix to begin a tag.  Merge the top-level tag and "Program" into one "tag".
 	{"Program:Field.Array[42].Member[16]", []string{"Program:Field", "Array", "42", "Member", "16"}, ""},
 	{"Program::Field.Array[42].Member[16]", nil, "non-alphabetic character ':'"},
@@ -76,3 +85,32 @@ func TestParser(t *testing.T) {
 		}
 	}
 }
+
+func TestParserBit(t *testing.T) {
+	tests := map[string]struct {
+		out []string
+		bit int
+	}{
+		"Status":                {[]string{"Status"}, NoBit},
+		"Status.0":              {[]string{"Status"}, 0},
+		"Status.07":             {[]string{"Status"}, 7},
+		"Motors[2].Status.63":   {[]string{"Motors", "2", "Status"}, 63},
+		"Program:Main.Status.5": {[]string{"Program:Main", "Status"}, 5},
+	}
+	for in, test := range tests {
+		out, bit, err := ParseQualifiedTagNameBit(in)
+		if err != nil {
+			t.Errorf(`ParseQualifiedTagNameBit("%v"): Got non-nil error %v`, in, err)
+		}
+		if !compareStrSlices(out, test.out) || bit != test.bit {
+			t.Errorf(`ParseQualifiedTagNameBit("%v"): Got "%v", %d, expected "%v", %d`, in, out, bit, test.out, test.bit)
+		}
+	}
+}
+
+func TestTagWithBit(t *testing.T) {
+	name := TagWithBit(TagWithIndex("Status", 2), 5)
+	if name != "Status[2].5" {
+		t.Errorf(`TagWithBit: Got "%v", expected "Status[2].5"`, name)
+	}
+}
@@ -1,6 +1,7 @@
 package plc
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -33,23 +34,40 @@ func rUnlockError(e error) TagLockerOperationError {
 // concurrent accesses on grabbing read or write access on a tree of locks
 // representing tag names (in the case of tree leaf nodes) and prefixes of tag
 // names (in the case of tree frond nodes).
+// A bit of an integer (e.g. "Status.3") locks the whole integer, since writing
+// a bit requires reading and writing the integer containing it.
 type TagLocker struct {
 	downstream ReadWriter
 	tagTree    *tagLockerNode
 }
 
+var _ = ContextReadWriter(&TagLocker{})  // Compiler makes sure this type is a ContextReadWriter
+var _ = ContextRangeReader(&TagLocker{}) // Compiler makes sure this type is a ContextRangeReader
+
 // ReadTag reads the given tag name from the downstream ReadWriter. If another
 // thread is concurrently writing to this tag or a prefix of the tag, we will
 // block until that thread has released its access.
-func (tl *TagLocker) ReadTag(name string, value interface{}) (err error) {
-	components, err := ParseQualifiedTagName(name)
+func (tl *TagLocker) ReadTag(name string, value interface{}) error {
+	return tl.ReadTagContext(context.Background(), name, value)
+}
+
+// ReadTagContext is the same as ReadTag, but it stops waiting for the lock if
+// ctx is done. The context is also passed to the downstream ReadWriter.
+func (tl *TagLocker) ReadTagContext(ctx context.Context, name string, value interface{}) (err error) {
+	defer func() { err = WrapOpError(err, "TagLocker", "ReadTag", name) }()
+
+	components, err := lockComponents(name)
 	if err != nil {
 		return
 	}
 
-	err = tl.tagTree.rLock(components)
+	err = lockContext(ctx,
+		func() error { return tl.tagTree.rLock(components) },
+		func() error { return tl.tagTree.rUnlock(components) })
 	if err != nil {
-		err = rLockError(err)
+		if ctx.Err() == nil {
+			err = rLockError(err)
+		}
 		return
 	}
 
@@ -61,7 +79,7 @@ func (tl *TagLocker) ReadTag(name string, value interface{}) (err error) {
 		}
 	}()
 
-	err = tl.downstream.ReadTag(name, value)
+	err = NewContextReader(tl.downstream).ReadTagContext(ctx, name, value)
 	if err != nil {
 		return
 	}
@@ -69,17 +87,63 @@ func (tl *TagLocker) ReadTag(name string, value interface{}) (err error) {
 	return
 }
 
+func (tl *TagLocker) ReadRange(name string, start int, value interface{}) error {
+	return tl.ReadRangeContext(context.Background(), name, start, value)
+}
+
+// ReadRangeContext reads a range of the named array from the downstream ReadWriter, with one call
+// if it's a RangeReader. The whole array is locked for reading, just like ReadTagContext.
+func (tl *TagLocker) ReadRangeContext(ctx context.Context, name string, start int, value interface{}) (err error) {
+	defer func() { err = WrapOpError(err, "TagLocker", "ReadRange", name) }()
+
+	components, err := lockComponents(name)
+	if err != nil {
+		return
+	}
+
+	err = lockContext(ctx,
+		func() error { return tl.tagTree.rLock(components) },
+		func() error { return tl.tagTree.rUnlock(components) })
+	if err != nil {
+		if ctx.Err() == nil {
+			err = rLockError(err)
+		}
+		return
+	}
+
+	defer func() {
+		unlockErr := tl.tagTree.rUnlock(components)
+		if unlockErr != nil {
+			err = rUnlockError(unlockErr)
+		}
+	}()
+
+	return ReadRangeContext(ctx, tl.downstream, name, start, value)
+}
+
 // WriteTag writes the given tag value to the downstream ReadWriter. Will block
 // if another thread is reading this tag or a prefix of the tag.
-func (tl *TagLocker) WriteTag(name string, value interface{}) (err error) {
-	components, err := ParseQualifiedTagName(name)
+func (tl *TagLocker) WriteTag(name string, value interface{}) error {
+	return tl.WriteTagContext(context.Background(), name, value)
+}
+
+// WriteTagContext is the same as WriteTag, but it stops waiting for the lock if
+// ctx is done. The context is also passed to the downstream ReadWriter.
+func (tl *TagLocker) WriteTagContext(ctx context.Context, name string, value interface{}) (err error) {
+	defer func() { err = WrapOpError(err, "TagLocker", "WriteTag", name) }()
+
+	components, err := lockComponents(name)
 	if err != nil {
 		return
 	}
 
-	err = tl.tagTree.lock(components)
+	err = lockContext(ctx,
+		func() error { return tl.tagTree.lock(components) },
+		func() error { return tl.tagTree.unlock(components) })
 	if err != nil {
-		err = lockError(err)
+		if ctx.Err() == nil {
+			err = lockError(err)
+		}
 		return
 	}
 
@@ -91,7 +155,7 @@ func (tl *TagLocker) WriteTag(name string, value interface{}) (err error) {
 		}
 	}()
 
-	err = tl.downstream.WriteTag(name, value)
+	err = NewContextWriter(tl.downstream).WriteTagContext(ctx, name, value)
 	if err != nil {
 		return
 	}
@@ -99,6 +163,56 @@ func (tl *TagLocker) WriteTag(name string, value interface{}) (err error) {
 	return
 }
 
+// lockComponents returns the components of the path through the lock tree for the tag.
+// Each is a segment of the tag's TagPath, so a field can't be confused with an index.
+// A bit locks the integer containing it.
+func lockComponents(name string) ([]string, error) {
+	path, err := ParseTagPath(name)
+	if err != nil {
+		return nil, err
+	}
+	path = path.Word()
+
+	components := make([]string, len(path))
+	for i, seg := range path {
+		if seg.Kind == SegmentSymbol {
+			components[i] = seg.Name
+		} else {
+			components[i] = seg.String() // e.g. "[2]", which can't be confused with a name
+		}
+	}
+	return components, nil
+}
+
+// lockContext calls lock, but returns early if ctx is done first. Since the
+// underlying locks can't be abandoned, a lock that is acquired after the caller
+// has given up is released in the background with unlock.
+func lockContext(ctx context.Context, lock, unlock func() error) error {
+	if ctx.Done() == nil {
+		return lock() // This context can never be done, so there's no need to wait in the background
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	locked := make(chan error, 1)
+	go func() {
+		locked <- lock()
+	}()
+
+	select {
+	case err := <-locked:
+		return err
+	case <-ctx.Done():
+		go func() {
+			if err := <-locked; err == nil {
+				unlock()
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
 type tagLockerNode struct {
 	mtx sync.RWMutex // Ensures mutual exclusion on the fields of the node.
 
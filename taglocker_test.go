@@ -5,6 +5,10 @@ import (
 	"math/rand"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Generates a random fully-qualified tag, and returns
@@ -66,6 +70,28 @@ func genPaths() []string {
 	return paths
 }
 
+func TestTagLockerLocksWordOfBit(t *testing.T) {
+	brw := newBlockingReadWriter()
+	tl := NewTagLocker(brw)
+
+	first := goWrite(tl, "Status.3", true)
+	assert.Equal(t, "Status.3=true", brw.waitForStart(t))
+
+	// Writing a different bit of the same word must wait, since it's a read-modify-write of the word.
+	second := goWrite(tl, "Status.4", true)
+	select {
+	case name := <-brw.started:
+		require.FailNow(t, "Second bit write should wait for the first", "Started %s", name)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	brw.release <- struct{}{}
+	assert.NoError(t, receiveError(t, first))
+	assert.Equal(t, "Status.4=true", brw.waitForStart(t))
+	brw.release <- struct{}{}
+	assert.NoError(t, receiveError(t, second))
+}
+
 func BenchmarkSerialTagLocking(b *testing.B) {
 	benchmarkTagLockLocking(b, serialTestConcurrency, testWritePrecentage)
 }
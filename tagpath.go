@@ -0,0 +1,168 @@
+package plc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TagSegmentKind is the kind of a TagSegment.
+type TagSegmentKind int
+
+const (
+	// SegmentProgram is a "Program:Name" scope. It can only be the first segment.
+	SegmentProgram TagSegmentKind = iota
+	// SegmentSymbol is the name of a tag or of a field of a struct.
+	SegmentSymbol
+	// SegmentIndex is an array index, which has more than one index for a multi-dimensional array.
+	SegmentIndex
+	// SegmentBit is a bit of an integer. It can only be the last segment.
+	SegmentBit
+)
+
+// TagSegment is one part of a TagPath.
+type TagSegment struct {
+	Kind    TagSegmentKind
+	Name    string // For SegmentProgram and SegmentSymbol
+	Indices []int  // For SegmentIndex
+	Bit     int    // For SegmentBit
+}
+
+// ProgramSegment returns a segment for the named program's scope.
+func ProgramSegment(name string) TagSegment {
+	return TagSegment{Kind: SegmentProgram, Name: name}
+}
+
+// SymbolSegment returns a segment for a tag or field name.
+func SymbolSegment(name string) TagSegment {
+	return TagSegment{Kind: SegmentSymbol, Name: name}
+}
+
+// IndexSegment returns a segment for an array index. Provide one index per dimension.
+func IndexSegment(indices ...int) TagSegment {
+	return TagSegment{Kind: SegmentIndex, Indices: indices}
+}
+
+// BitSegment returns a segment for a bit of an integer.
+func BitSegment(bit int) TagSegment {
+	return TagSegment{Kind: SegmentBit, Bit: bit}
+}
+
+// String formats the segment as it appears after a previous segment, e.g. ".Name" or "[1,2]".
+func (seg TagSegment) String() string {
+	switch seg.Kind {
+	case SegmentProgram:
+		return "Program:" + seg.Name
+	case SegmentSymbol:
+		return "." + seg.Name
+	case SegmentIndex:
+		strs := make([]string, len(seg.Indices))
+		for i, idx := range seg.Indices {
+			strs[i] = strconv.Itoa(idx)
+		}
+		return "[" + strings.Join(strs, ",") + "]"
+	case SegmentBit:
+		return "." + strconv.Itoa(seg.Bit)
+	default:
+		return ""
+	}
+}
+
+func (seg TagSegment) equal(other TagSegment) bool {
+	if seg.Kind != other.Kind || seg.Name != other.Name || seg.Bit != other.Bit || len(seg.Indices) != len(other.Indices) {
+		return false
+	}
+	for i := range seg.Indices {
+		if seg.Indices[i] != other.Indices[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TagPath is a parsed tag name. Unlike the components returned by ParseQualifiedTagName, each
+// segment has a kind, so a field can be told apart from an array index or a bit.
+//
+// The methods never modify a TagPath, so it's safe to derive several paths from the same parent.
+type TagPath []TagSegment
+
+// String returns the tag name. It round-trips with ParseTagPath, though whitespace and leading
+// zeros in numbers aren't preserved.
+func (path TagPath) String() string {
+	var sb strings.Builder
+	for i, seg := range path {
+		str := seg.String()
+		if i == 0 && seg.Kind == SegmentSymbol {
+			str = str[1:] // A tag name doesn't start with a '.'
+		}
+		sb.WriteString(str)
+	}
+	return sb.String()
+}
+
+// Parent returns the path without its last segment. The parent of an empty path is empty.
+func (path TagPath) Parent() TagPath {
+	if len(path) == 0 {
+		return nil
+	}
+	return path[: len(path)-1 : len(path)-1]
+}
+
+// Child returns the path with the segment appended.
+func (path TagPath) Child(seg TagSegment) TagPath {
+	return append(path[:len(path):len(path)], seg)
+}
+
+// Join returns the path with all of other's segments appended.
+func (path TagPath) Join(other TagPath) TagPath {
+	return append(path[:len(path):len(path)], other...)
+}
+
+// HasPrefix returns true if the path starts with all of prefix's segments. A path is a
+// prefix of itself.
+func (path TagPath) HasPrefix(prefix TagPath) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if !path[i].equal(prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Bit returns the number of the trailing bit segment, or NoBit if there isn't one.
+func (path TagPath) Bit() int {
+	if len(path) == 0 || path[len(path)-1].Kind != SegmentBit {
+		return NoBit
+	}
+	return path[len(path)-1].Bit
+}
+
+// Word returns the path of the integer containing the bit, or the path itself if it doesn't
+// end in a bit segment.
+func (path TagPath) Word() TagPath {
+	if path.Bit() == NoBit {
+		return path
+	}
+	return path.Parent()
+}
+
+// components returns the path in the form returned by ParseQualifiedTagName.
+func (path TagPath) components() []string {
+	var ret []string
+	for _, seg := range path {
+		switch seg.Kind {
+		case SegmentProgram, SegmentSymbol:
+			ret = append(ret, seg.Name)
+		case SegmentIndex:
+			for _, idx := range seg.Indices {
+				ret = append(ret, strconv.Itoa(idx))
+			}
+		}
+	}
+	if len(path) > 0 && path[0].Kind == SegmentProgram {
+		ret[0] = "Program:" + ret[0]
+	}
+	return ret
+}
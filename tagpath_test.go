@@ -0,0 +1,95 @@
+package plc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTagPath(t *testing.T) {
+	tests := map[string]TagPath{
+		"Status":           {SymbolSegment("Status")},
+		"Motor.Speed":      {SymbolSegment("Motor"), SymbolSegment("Speed")},
+		"Arr[1][2]":        {SymbolSegment("Arr"), IndexSegment(1), IndexSegment(2)},
+		"Arr[1,2,3]":       {SymbolSegment("Arr"), IndexSegment(1, 2, 3)},
+		"Status.3":         {SymbolSegment("Status"), BitSegment(3)},
+		"Program:Main":     {ProgramSegment("Main")},
+		"Program:Main.X":   {ProgramSegment("Main"), SymbolSegment("X")},
+		"M[0].Status.31":   {SymbolSegment("M"), IndexSegment(0), SymbolSegment("Status"), BitSegment(31)},
+		"Program:P.A[4,5]": {ProgramSegment("P"), SymbolSegment("A"), IndexSegment(4, 5)},
+	}
+	for name, expected := range tests {
+		path, err := ParseTagPath(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, path, name)
+		assert.Equal(t, name, path.String(), "String should round-trip")
+	}
+
+	path, err := ParseTagPath("Arr[ 01 , 2 ]")
+	require.NoError(t, err)
+	assert.Equal(t, "Arr[1,2]", path.String())
+
+	_, err = ParseTagPath("Arr[")
+	assert.Error(t, err)
+}
+
+func TestTagPathParentChildJoin(t *testing.T) {
+	path, err := ParseTagPath("Motors[2].Status")
+	require.NoError(t, err)
+
+	parent := path.Parent()
+	assert.Equal(t, "Motors[2]", parent.String())
+	assert.Equal(t, "Motors", parent.Parent().String())
+	assert.Empty(t, parent.Parent().Parent().Parent())
+
+	// Deriving two children from the same parent must not let them share storage
+	speed := parent.Child(SymbolSegment("Speed"))
+	bit := parent.Child(BitSegment(4))
+	assert.Equal(t, "Motors[2].Speed", speed.String())
+	assert.Equal(t, "Motors[2].4", bit.String())
+	assert.Equal(t, "Motors[2].Status", path.String())
+
+	joined := TagPath{SymbolSegment("Line")}.Join(path)
+	assert.Equal(t, "Line.Motors[2].Status", joined.String())
+	assert.Equal(t, "[0]", TagPath(nil).Child(IndexSegment(0)).String())
+}
+
+func TestTagPathHasPrefix(t *testing.T) {
+	parse := func(name string) TagPath {
+		path, err := ParseTagPath(name)
+		require.NoError(t, err)
+		return path
+	}
+
+	assert.True(t, parse("Motors[2].Status").HasPrefix(parse("Motors")))
+	assert.True(t, parse("Motors[2].Status").HasPrefix(parse("Motors[2]")))
+	assert.True(t, parse("Motors[2]").HasPrefix(parse("Motors[2]")))
+	assert.False(t, parse("Motors").HasPrefix(parse("Motors[2]")))
+	assert.False(t, parse("Motors[2]").HasPrefix(parse("Motors[3]")))
+	assert.False(t, parse("Arr[0,1]").HasPrefix(parse("Arr[0]")), "A multi-dimensional index isn't a nested index")
+	assert.False(t, parse("MotorsX").HasPrefix(parse("Motors")))
+}
+
+func TestTagPathBitAndWord(t *testing.T) {
+	path, err := ParseTagPath("Motor.Status.5")
+	require.NoError(t, err)
+	assert.Equal(t, 5, path.Bit())
+	assert.Equal(t, "Motor.Status", path.Word().String())
+
+	word := path.Word()
+	assert.Equal(t, NoBit, word.Bit())
+	assert.Equal(t, word, word.Word())
+}
+
+func TestTagWithIndexUsesTagPath(t *testing.T) {
+	assert.Equal(t, "Arr[1][2]", TagWithIndex(TagWithIndex("Arr", 1), 2))
+	assert.Equal(t, "Arr[1][2]", TagWithIndex("Arr[ 1 ]", 2), "The name should be normalized")
+	assert.Equal(t, "[3]", TagWithIndex("", 3), "A name which can't be parsed is used as-is")
+}
+
+func TestTagWithIndices(t *testing.T) {
+	assert.Equal(t, "Grid[1,2]", TagWithIndices("Grid", 1, 2))
+	assert.Equal(t, "Line.Grid[1,2,3]", TagWithIndices("Line.Grid", 1, 2, 3))
+	assert.Equal(t, "[4,5]", TagWithIndices("", 4, 5))
+}